@@ -0,0 +1,69 @@
+package apierror
+
+import (
+	"fmt"
+	"time"
+)
+
+// Code classifies an error for clients, which receive it as the code
+// extension of GraphQL errors.
+type Code string
+
+const (
+	CodeUnauthenticated Code = "UNAUTHENTICATED"
+	CodeForbidden       Code = "FORBIDDEN"
+	CodeNotFound        Code = "NOT_FOUND"
+	CodeConflict        Code = "CONFLICT"
+	CodeValidation      Code = "VALIDATION"
+	CodeRateLimited     Code = "RATE_LIMITED"
+	CodeQueryTooComplex Code = "QUERY_TOO_COMPLEX"
+	CodeTimeout         Code = "TIMEOUT"
+	CodeInternal        Code = "INTERNAL"
+)
+
+// Error is an error that's safe to show to clients. Any other error is
+// treated as internal.
+type Error struct {
+	Code    Code
+	Message string
+	// Field is the input the error is about, if any.
+	Field string
+	// RetryAfter is how long until a rate limited request may be retried.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrUnauthenticated = &Error{Code: CodeUnauthenticated, Message: "unauthorized"}
+	ErrForbidden       = &Error{Code: CodeForbidden, Message: "forbidden"}
+)
+
+func New(code Code, format string, args ...interface{}) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func NotFound(format string, args ...interface{}) error {
+	return New(CodeNotFound, format, args...)
+}
+
+func Invalid(format string, args ...interface{}) error {
+	return New(CodeValidation, format, args...)
+}
+
+// Conflict reports that field's value is already taken.
+func Conflict(field, format string, args ...interface{}) error {
+	return &Error{Code: CodeConflict, Message: fmt.Sprintf(format, args...), Field: field}
+}
+
+// InvalidField reports that field's value is invalid.
+func InvalidField(field, format string, args ...interface{}) error {
+	return &Error{Code: CodeValidation, Message: fmt.Sprintf(format, args...), Field: field}
+}
+
+// RateLimited reports that a request may not be retried until after wait.
+func RateLimited(wait time.Duration, format string, args ...interface{}) error {
+	return &Error{Code: CodeRateLimited, Message: fmt.Sprintf(format, args...), RetryAfter: wait}
+}
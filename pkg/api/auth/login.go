@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"strings"
+	"time"
+)
+
+// After LockoutThreshold logins in a row fail, an email is locked out for
+// LockoutBase, and then for twice as long after each further failure up to
+// LockoutMax.
+const (
+	LockoutThreshold = 5
+	LockoutBase      = time.Minute
+	LockoutMax       = time.Hour
+)
+
+// ErrLoginInvalid is the error of every failed login, so that logins don't
+// reveal which emails have accounts.
+var ErrLoginInvalid = &apierror.Error{Code: apierror.CodeUnauthenticated, Message: "invalid email or password"}
+
+// LoginLimited is the error of logins that are refused because there have
+// been too many, whether by rate limits or lockout.
+func LoginLimited(wait time.Duration) error {
+	return apierror.RateLimited(wait, "too many login attempts, try again later")
+}
+
+// unknownSalt derives a key for logins to unknown emails, so that they take as
+// long as logins to known emails.
+var unknownSalt = make([]byte, SaltLen)
+
+// Lockout returns how long an email is locked out after failures logins in a
+// row fail.
+func Lockout(failures int) time.Duration {
+	if failures < LockoutThreshold {
+		return 0
+	}
+	n := failures - LockoutThreshold
+	if n >= 32 || LockoutBase<<n > LockoutMax {
+		return LockoutMax
+	}
+	return LockoutBase << n
+}
+
+// Login checks the password of the user with an email and returns their ID.
+// Emails are matched regardless of case. Unknown emails and locked out emails
+// fail the same way, and as slowly, as wrong passwords.
+func Login(db *database.DB, email, password string) (int, error) {
+	email = strings.ToLower(email)
+	var failure database.LoginFailure
+	if err := db.Where(&database.LoginFailure{Email: email}).Limit(1).Find(&failure).Error; err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+	var user database.User
+	found := true
+	if err := db.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("database error: %w", err)
+		}
+		found = false
+		user.Salt = unknownSalt
+	}
+	key := Key([]byte(password), user.Salt)
+	now := time.Now()
+	if failure.LockedUntil != nil && now.Before(*failure.LockedUntil) {
+		return 0, ErrLoginInvalid
+	}
+	if !found || subtle.ConstantTimeCompare(key, user.Key) != 1 {
+		if err := fail(db, email, now); err != nil {
+			return 0, err
+		}
+		return 0, ErrLoginInvalid
+	}
+	if failure.Failures > 0 {
+		if err := ClearLoginFailures(db, email); err != nil {
+			return 0, err
+		}
+	}
+	return user.ID, nil
+}
+
+// fail counts a failed login to a lowercased email and locks it out if there
+// have been too many.
+func fail(db *database.DB, email string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		failure := database.LoginFailure{Email: email, Failures: 1, Updated: now}
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "email"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures": gorm.Expr("login_failures.failures + 1"),
+				"updated":  now,
+			}),
+		}).Create(&failure).Error
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if err := tx.Where(&database.LoginFailure{Email: email}).First(&failure).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if lockout := Lockout(failure.Failures); lockout > 0 {
+			err := tx.Model(&failure).Update("locked_until", now.Add(lockout)).Error
+			if err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ClearLoginFailures forgets failed logins to an email, ending any lockout.
+func ClearLoginFailures(tx *gorm.DB, email string) error {
+	err := tx.Where(&database.LoginFailure{Email: strings.ToLower(email)}).Delete(&database.LoginFailure{}).Error
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	return nil
+}
+
+// PruneLoginFailures forgets failed logins to emails that haven't failed since
+// before and aren't locked out.
+func PruneLoginFailures(db *database.DB, before time.Time) error {
+	err := db.Where("updated < ? AND (locked_until IS NULL OR locked_until < ?)", before, time.Now()).
+		Delete(&database.LoginFailure{}).Error
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	return nil
+}
+
+// PruneLoginFailuresEvery prunes failed logins that are older than interval,
+// every interval until ctx is done.
+func PruneLoginFailuresEvery(ctx context.Context, db *database.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := PruneLoginFailures(db.WithContext(ctx), now.Add(-interval)); err != nil {
+				log.Printf("login failure prune error: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
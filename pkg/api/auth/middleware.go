@@ -2,9 +2,8 @@ package auth
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
 	"github.com/golang-jwt/jwt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
 	"log"
 	"net/http"
 	"strings"
@@ -20,59 +19,128 @@ var userCtxKey = &contextKey{
 }
 
 type UserClaims struct {
-	UserID int `json:"userId"`
+	UserID    int    `json:"userId"`
+	SessionID int    `json:"sid"`
+	Role      string `json:"role"`
 	jwt.StandardClaims
 }
 
 type UserAuth struct {
-	UserID int
+	UserID    int
+	SessionID int
+	Role      string
 }
 
-var signKey *rsa.PrivateKey
-var verifyKey *rsa.PublicKey
+var keys *KeyRing
 
-func Token(userId int, expiresIn time.Duration) (string, int, error) {
+// SetKeyRing replaces the key ring used to sign and verify tokens.
+func SetKeyRing(ring *KeyRing) {
+	keys = ring
+}
+
+func Token(userId, sessionId int, role string, expiresIn time.Duration) (string, int, error) {
 	now := time.Now()
 	claims := UserClaims{
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: now.Add(expiresIn).Unix(),
 			IssuedAt:  now.Unix(),
 		},
-		UserID: userId,
+		UserID:    userId,
+		SessionID: sessionId,
+		Role:      role,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	signedToken, err := token.SignedString(signKey)
+	signedToken, err := keys.Sign(claims)
 	return signedToken, int(claims.StandardClaims.ExpiresAt), err
 }
 
-func Handle(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract token from header.
-		header := r.Header.Get("Authorization")
-		var bearer string
-		if parts := strings.Split(header, "Bearer "); len(parts) > 1 {
-			bearer = parts[1]
-		}
-		// Parse token to claims.
-		var claims *UserClaims
-		token, err := jwt.ParseWithClaims(bearer, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return verifyKey, nil
+// Authenticate returns the user authenticated by an Authorization header, or
+// nil if it doesn't carry a valid access token of an active session. The user's
+// role is read along with the session rather than taken from the token, so
+// that role changes apply to tokens already issued.
+func Authenticate(ctx context.Context, db *database.DB, header string) *UserAuth {
+	// Extract token from header.
+	var bearer string
+	if parts := strings.Split(header, "Bearer "); len(parts) > 1 {
+		bearer = parts[1]
+	}
+	if bearer == "" {
+		return nil
+	}
+	// Parse token to claims.
+	var claims *UserClaims
+	token, err := jwt.ParseWithClaims(bearer, &UserClaims{}, keys.Keyfunc)
+	if err != nil {
+		log.Print(err) // TODO: improve error message
+	} else if token.Valid {
+		claims = token.Claims.(*UserClaims)
+	}
+	if claims == nil {
+		return nil
+	}
+	// Check session hasn't been revoked.
+	role, err := SessionRole(db.WithContext(ctx), claims.UserID, claims.SessionID)
+	if err != nil {
+		log.Printf("session check error: %v", err)
+	}
+	if role == "" {
+		return nil
+	}
+	return &UserAuth{
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+		Role:      role,
+	}
+}
+
+// WithUserAuth stores user auth in a context.
+func WithUserAuth(ctx context.Context, userAuth *UserAuth) context.Context {
+	return context.WithValue(ctx, userCtxKey, userAuth)
+}
+
+// Handle authenticates requests bearing an access token. Tokens are only
+// accepted while the session they were issued for hasn't been revoked.
+func Handle(db *database.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if userAuth := Authenticate(r.Context(), db, r.Header.Get("Authorization")); userAuth != nil {
+				r = r.WithContext(WithUserAuth(r.Context(), userAuth))
+			}
+			next.ServeHTTP(w, r)
 		})
-		if err != nil {
-			log.Print(err) // TODO: improve error message
-		} else if token.Valid {
-			claims = token.Claims.(*UserClaims)
-		}
-		// Store user auth in context.
-		if claims != nil {
-			userAuth := UserAuth{
-				UserID: claims.UserID,
+	}
+}
+
+// SessionCheckInterval is how often long-lived connections check that their
+// session is still active.
+const SessionCheckInterval = time.Minute
+
+// WatchSession returns a context that is cancelled once the session of
+// userAuth is revoked or expires, or the user's role changes, checking every
+// interval, so that long-lived connections such as subscriptions end with their
+// session and reconnect with the new role.
+func WatchSession(ctx context.Context, db *database.DB, userAuth *UserAuth, interval time.Duration) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			role, err := SessionRole(db.WithContext(ctx), userAuth.UserID, userAuth.SessionID)
+			if err != nil {
+				log.Printf("session check error: %v", err)
+				continue
+			}
+			if role != userAuth.Role {
+				return
 			}
-			ctx := context.WithValue(r.Context(), userCtxKey, &userAuth)
-			r = r.WithContext(ctx)
 		}
-		next.ServeHTTP(w, r)
-	})
+	}()
+	return ctx
 }
 
 func ForContext(ctx context.Context) *UserAuth {
@@ -83,11 +151,12 @@ func ForContext(ctx context.Context) *UserAuth {
 }
 
 func init() {
-	var err error
-	// TODO: Load a persistent key from
-	signKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	ring, generated, err := LoadKeyRing()
 	if err != nil {
-		log.Fatalf("error generating sign key: %v", err)
+		log.Fatalf("error loading sign keys: %v", err)
+	}
+	if generated {
+		log.Printf("no %s or %s set, using generated sign key (development only)", SignKeyEnv, SignKeyFileEnv)
 	}
-	verifyKey = &signKey.PublicKey
+	keys = ring
 }
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"gorm.io/gorm"
+	"time"
+)
+
+const PasswordResetTTL = time.Hour
+
+var ErrResetInvalid = &apierror.Error{Code: apierror.CodeValidation, Message: "password reset token invalid"}
+
+// CreatePasswordReset issues a single-use password reset token for a user.
+func CreatePasswordReset(db *database.DB, userID int) (string, error) {
+	secret, hash, err := Secret()
+	if err != nil {
+		return "", fmt.Errorf("reset token error: %w", err)
+	}
+	now := time.Now()
+	reset := database.PasswordReset{
+		UserID:    userID,
+		TokenHash: hash,
+		Created:   now,
+		Expires:   now.Add(PasswordResetTTL),
+	}
+	if err := db.Create(&reset).Error; err != nil {
+		return "", fmt.Errorf("database error: %w", err)
+	}
+	return secret, nil
+}
+
+// ResetPassword consumes a password reset token and sets a new password. All
+// of the user's sessions are revoked as the old password may have been known
+// to someone else.
+func ResetPassword(db *database.DB, token, password string) (int, error) {
+	var userID int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var reset database.PasswordReset
+		if err := tx.Where(&database.PasswordReset{TokenHash: SecretHash(token)}).First(&reset).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrResetInvalid
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		now := time.Now()
+		if reset.Used != nil || !now.Before(reset.Expires) {
+			return ErrResetInvalid
+		}
+		// Only one of concurrent uses of the token may succeed.
+		res := tx.Model(&reset).Where("used IS NULL").Update("used", now)
+		if res.Error != nil {
+			return fmt.Errorf("database error: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return ErrResetInvalid
+		}
+		key, salt, err := Encode([]byte(password))
+		if err != nil {
+			return fmt.Errorf("password encode error: %w", err)
+		}
+		user := database.User{ID: reset.UserID}
+		err = tx.Model(&user).Updates(map[string]interface{}{"key": key, "salt": salt}).Error
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		// A new password also ends any lockout after failed logins.
+		if err := tx.First(&user).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if err := ClearLoginFailures(tx, user.Email); err != nil {
+			return err
+		}
+		if _, err := RevokeUserSessions(tx, reset.UserID); err != nil {
+			return err
+		}
+		userID = reset.UserID
+		return nil
+	})
+	return userID, err
+}
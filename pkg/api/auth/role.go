@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+)
+
+func roleRank(role string) int {
+	for i, r := range database.Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// HasRole reports whether a user with role have may act as role want.
+func HasRole(have, want string) bool {
+	rank := roleRank(have)
+	return rank >= 0 && rank >= roleRank(want)
+}
+
+func ValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"testing"
+)
+
+func TestHasRole(t *testing.T) {
+	for _, test := range []struct {
+		have, want string
+		ok         bool
+	}{
+		{database.RoleMember, database.RoleMember, true},
+		{database.RoleMember, database.RoleAdmin, false},
+		{database.RoleAdmin, database.RoleModerator, true},
+		{database.RoleAdmin, database.RoleDictator, false},
+		{database.RoleDictator, database.RoleAdmin, true},
+		{"", database.RoleMember, false},
+		{"KING", database.RoleMember, false},
+	} {
+		if ok := HasRole(test.have, test.want); ok != test.ok {
+			t.Errorf("HasRole(%q, %q): expected %v", test.have, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AccessTokenTTL  = time.Minute * 15
+	RefreshTokenTTL = time.Hour * 24 * 30
+	SecretLen       = 32
+)
+
+var (
+	ErrRefreshInvalid = &apierror.Error{Code: apierror.CodeUnauthenticated, Message: "refresh token invalid"}
+	ErrRefreshReused  = &apierror.Error{Code: apierror.CodeUnauthenticated, Message: "refresh token reused"}
+)
+
+// SessionToken is the pair of tokens issued when a session is opened or
+// refreshed.
+type SessionToken struct {
+	AccessToken      string
+	AccessExpiresAt  int
+	RefreshToken     string
+	RefreshExpiresAt int
+}
+
+// Secret generates a random token secret and the hash of it to store. Only
+// hashes are stored so a database leak doesn't leak usable tokens.
+func Secret() (string, []byte, error) {
+	b := make([]byte, SecretLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	return secret, SecretHash(secret), nil
+}
+
+func SecretHash(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// Refresh tokens have the form <session id>.<secret>.
+func parseRefreshToken(token string) (int, string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return 0, "", ErrRefreshInvalid
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", ErrRefreshInvalid
+	}
+	return id, parts[1], nil
+}
+
+func issue(db *database.DB, session *database.Session, secret string) (*SessionToken, error) {
+	// The role is read afresh whenever tokens are issued, so a change of role
+	// applies once current access tokens expire.
+	var user database.User
+	if err := db.Select("role").Where(&database.User{ID: session.UserID}).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	access, accessExpiresAt, err := Token(session.UserID, session.ID, user.Role, AccessTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("access token error: %w", err)
+	}
+	return &SessionToken{
+		AccessToken:      access,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     fmt.Sprintf("%d.%s", session.ID, secret),
+		RefreshExpiresAt: int(session.Expires.Unix()),
+	}, nil
+}
+
+// OpenSession starts a new session for a user that has just authenticated.
+func OpenSession(db *database.DB, userID int) (*SessionToken, error) {
+	secret, hash, err := Secret()
+	if err != nil {
+		return nil, fmt.Errorf("refresh token error: %w", err)
+	}
+	now := time.Now()
+	session := database.Session{
+		UserID:      userID,
+		Created:     now,
+		Expires:     now.Add(RefreshTokenTTL),
+		RefreshHash: hash,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return issue(db, &session, secret)
+}
+
+// RefreshSession exchanges a refresh token for a new token pair, rotating the
+// refresh token. Presenting a refresh token that has already been rotated
+// means it has been copied, so the whole session is revoked. Any other token
+// is just invalid, so guessing tokens can't revoke sessions.
+func RefreshSession(db *database.DB, token string) (*SessionToken, error) {
+	id, secret, err := parseRefreshToken(token)
+	if err != nil {
+		return nil, err
+	}
+	var out *SessionToken
+	var reused bool
+	err = db.Transaction(func(tx *gorm.DB) error {
+		next, hash, err := Secret()
+		if err != nil {
+			return fmt.Errorf("refresh token error: %w", err)
+		}
+		// Only one refresh can rotate the token, even if they're concurrent.
+		now := time.Now()
+		current := SecretHash(secret)
+		res := tx.Model(&database.Session{}).
+			Where("id = ? AND refresh_hash = ? AND revoked IS NULL AND expires > ?", id, current, now).
+			Update("refresh_hash", hash)
+		if res.Error != nil {
+			return fmt.Errorf("database error: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			var count int64
+			err := tx.Model(&database.RefreshRotation{}).
+				Where(&database.RefreshRotation{RefreshHash: current, SessionID: id}).
+				Count(&count).Error
+			if err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+			if count == 0 {
+				return ErrRefreshInvalid
+			}
+			err = tx.Model(&database.Session{}).
+				Where("id = ? AND revoked IS NULL", id).
+				Update("revoked", now).Error
+			if err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+			reused = true
+			return nil
+		}
+		rotation := database.RefreshRotation{RefreshHash: current, SessionID: id, Rotated: now}
+		if err := tx.Create(&rotation).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		var session database.Session
+		if err := tx.Where(&database.Session{ID: id}).First(&session).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		out, err = issue(tx, &session, next)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The revocation on reuse must be committed, so it is reported only after
+	// the transaction.
+	if reused {
+		return nil, ErrRefreshReused
+	}
+	return out, nil
+}
+
+// RevokeSession revokes a single session of a user.
+func RevokeSession(db *database.DB, userID, sessionID int) (bool, error) {
+	res := db.Model(&database.Session{}).
+		Where("id = ? AND user_id = ? AND revoked IS NULL", sessionID, userID).
+		Update("revoked", time.Now())
+	if res.Error != nil {
+		return false, fmt.Errorf("database error: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// RevokeUserSessions revokes every active session of a user and returns how
+// many were revoked.
+func RevokeUserSessions(db *database.DB, userID int) (int, error) {
+	res := db.Model(&database.Session{}).
+		Where("user_id = ? AND revoked IS NULL", userID).
+		Update("revoked", time.Now())
+	if res.Error != nil {
+		return 0, fmt.Errorf("database error: %w", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
+
+// SessionRole returns the current role of the user of a session, or "" if
+// access tokens of the session may no longer be used.
+func SessionRole(db *database.DB, userID, sessionID int) (string, error) {
+	var roles []string
+	err := db.Model(&database.Session{}).
+		Joins("JOIN users ON users.id = sessions.user_id").
+		Where("sessions.id = ? AND sessions.user_id = ? AND sessions.revoked IS NULL AND sessions.expires > ?", sessionID, userID, time.Now()).
+		Limit(1).
+		Pluck("users.role", &roles).Error
+	if err != nil || len(roles) == 0 {
+		return "", err
+	}
+	return roles[0], nil
+}
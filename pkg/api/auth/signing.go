@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"os"
+)
+
+const (
+	SignKeyEnv         = "JWT_SIGN_KEY"
+	SignKeyFileEnv     = "JWT_SIGN_KEY_FILE"
+	VerifyKeysEnv      = "JWT_VERIFY_KEYS"
+	VerifyKeysFileEnv  = "JWT_VERIFY_KEYS_FILE"
+	DevelopmentKeyBits = 2048
+)
+
+// SigningKey is an RSA key pair identified by the kid header of the tokens it
+// signs.
+type SigningKey struct {
+	ID      string
+	Private *rsa.PrivateKey
+	Public  *rsa.PublicKey
+}
+
+// KeyID derives a stable key identifier from the public key so that replicas
+// loading the same PEM agree on the kid without further configuration.
+func KeyID(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+func NewSigningKey(private *rsa.PrivateKey) (*SigningKey, error) {
+	id, err := KeyID(&private.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("key id error: %w", err)
+	}
+	return &SigningKey{
+		ID:      id,
+		Private: private,
+		Public:  &private.PublicKey,
+	}, nil
+}
+
+// KeyRing holds the key used to sign new tokens and every key that tokens may
+// still be verified with. Retired keys stay in the ring for a rotation window
+// so that tokens issued before the rotation remain valid until they expire.
+type KeyRing struct {
+	sign   *SigningKey
+	verify map[string]*rsa.PublicKey
+}
+
+func NewKeyRing(sign *SigningKey, retired ...*rsa.PublicKey) (*KeyRing, error) {
+	ring := KeyRing{
+		sign: sign,
+		verify: map[string]*rsa.PublicKey{
+			sign.ID: sign.Public,
+		},
+	}
+	for _, key := range retired {
+		id, err := KeyID(key)
+		if err != nil {
+			return nil, fmt.Errorf("key id error: %w", err)
+		}
+		ring.verify[id] = key
+	}
+	return &ring, nil
+}
+
+func (k *KeyRing) SigningKey() *SigningKey {
+	return k.sign
+}
+
+// VerifyKey returns the public key for a kid. Tokens issued before key IDs were
+// introduced carry no kid and are checked against the current signing key.
+func (k *KeyRing) VerifyKey(id string) (*rsa.PublicKey, error) {
+	if id == "" {
+		return k.sign.Public, nil
+	}
+	key, ok := k.verify[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+	return key, nil
+}
+
+// Sign signs claims with the current signing key and sets the kid header.
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.sign.ID
+	return token.SignedString(k.sign.Private)
+}
+
+// Keyfunc resolves the verification key for a parsed token.
+func (k *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+	id, _ := token.Header["kid"].(string)
+	return k.VerifyKey(id)
+}
+
+// LoadKeyRing builds a key ring from the environment. The signing key is read
+// from JWT_SIGN_KEY or JWT_SIGN_KEY_FILE and retired keys from JWT_VERIFY_KEYS
+// or JWT_VERIFY_KEYS_FILE, each holding one or more PEM blocks. If no signing
+// key is configured an ephemeral key is generated, which is only suitable for
+// development as tokens won't survive a restart or be valid on other replicas.
+func LoadKeyRing() (ring *KeyRing, generated bool, err error) {
+	signPEM, err := readEnvOrFile(SignKeyEnv, SignKeyFileEnv)
+	if err != nil {
+		return nil, false, err
+	}
+	var private *rsa.PrivateKey
+	if signPEM == nil {
+		private, err = rsa.GenerateKey(rand.Reader, DevelopmentKeyBits)
+		if err != nil {
+			return nil, false, fmt.Errorf("generate sign key error: %w", err)
+		}
+		generated = true
+	} else if private, err = jwt.ParseRSAPrivateKeyFromPEM(signPEM); err != nil {
+		return nil, false, fmt.Errorf("parse sign key error: %w", err)
+	}
+	sign, err := NewSigningKey(private)
+	if err != nil {
+		return nil, false, err
+	}
+	verifyPEM, err := readEnvOrFile(VerifyKeysEnv, VerifyKeysFileEnv)
+	if err != nil {
+		return nil, false, err
+	}
+	retired, err := ParsePublicKeysFromPEM(verifyPEM)
+	if err != nil {
+		return nil, false, fmt.Errorf("parse verify keys error: %w", err)
+	}
+	ring, err = NewKeyRing(sign, retired...)
+	if err != nil {
+		return nil, false, err
+	}
+	return ring, generated, nil
+}
+
+// ParsePublicKeysFromPEM parses every PEM block in b as an RSA public key.
+// Private key blocks are accepted too, in which case their public half is used.
+func ParsePublicKeysFromPEM(b []byte) ([]*rsa.PublicKey, error) {
+	var keys []*rsa.PublicKey
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return keys, nil
+		}
+		one := pem.EncodeToMemory(block)
+		switch block.Type {
+		case "RSA PRIVATE KEY", "PRIVATE KEY":
+			key, err := jwt.ParseRSAPrivateKeyFromPEM(one)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, &key.PublicKey)
+		default:
+			key, err := jwt.ParseRSAPublicKeyFromPEM(one)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+}
+
+func readEnvOrFile(env, fileEnv string) ([]byte, error) {
+	if v := os.Getenv(env); v != "" {
+		return []byte(v), nil
+	}
+	if path := os.Getenv(fileEnv); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read %s error: %w", fileEnv, err)
+		}
+		return b, nil
+	}
+	return nil, nil
+}
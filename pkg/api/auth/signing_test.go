@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt"
+	"testing"
+)
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func privatePEM(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func publicPEM(t *testing.T, key *rsa.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// loadTestKeyRing loads a key ring from the environment signing with sign and
+// verifying with retired too.
+func loadTestKeyRing(t *testing.T, sign *rsa.PrivateKey, retired ...string) *KeyRing {
+	t.Helper()
+	t.Setenv(SignKeyEnv, privatePEM(sign))
+	verify := ""
+	for _, key := range retired {
+		verify += key
+	}
+	t.Setenv(VerifyKeysEnv, verify)
+	ring, generated, err := LoadKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generated {
+		t.Fatal("expected the configured signing key to be used")
+	}
+	return ring
+}
+
+func verifyTestToken(ring *KeyRing, token string) error {
+	_, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, ring.Keyfunc)
+	return err
+}
+
+func TestLoadKeyRingVerifiesRetiredKeys(t *testing.T) {
+	old := newTestKey(t)
+	oldRing := loadTestKeyRing(t, old)
+	token, err := oldRing.Sign(&jwt.StandardClaims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After a rotation, tokens signed with the retired key still verify by
+	// their kid, whether the key was given as a public or private PEM block.
+	current := newTestKey(t)
+	for _, retired := range []string{publicPEM(t, &old.PublicKey), privatePEM(old)} {
+		ring := loadTestKeyRing(t, current, retired)
+		if ring.SigningKey().ID == oldRing.SigningKey().ID {
+			t.Fatal("expected the key ids of different keys to differ")
+		}
+		if err := verifyTestToken(ring, token); err != nil {
+			t.Errorf("expected a token of a retired key to verify, got %v", err)
+		}
+	}
+
+	// Once the key is dropped from the ring, its tokens are refused.
+	ring := loadTestKeyRing(t, current)
+	if err := verifyTestToken(ring, token); err == nil {
+		t.Error("expected a token of an unknown key to be refused")
+	}
+}
+
+func TestLoadKeyRingRejectsUnknownKeyID(t *testing.T) {
+	key := newTestKey(t)
+	ring := loadTestKeyRing(t, key)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, &jwt.StandardClaims{Subject: "1"})
+	token.Header["kid"] = "unknown"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyTestToken(ring, signed); err == nil {
+		t.Error("expected a token with an unknown kid to be refused")
+	}
+}
+
+func TestLoadKeyRingVerifiesTokensWithoutKeyIDWithSigningKey(t *testing.T) {
+	old := newTestKey(t)
+	current := newTestKey(t)
+	ring := loadTestKeyRing(t, current, publicPEM(t, &old.PublicKey))
+
+	withoutKeyID := func(key *rsa.PrivateKey) string {
+		t.Helper()
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, &jwt.StandardClaims{Subject: "1"}).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	if err := verifyTestToken(ring, withoutKeyID(current)); err != nil {
+		t.Errorf("expected a token without a kid to verify with the signing key, got %v", err)
+	}
+	if err := verifyTestToken(ring, withoutKeyID(old)); err == nil {
+		t.Error("expected a token without a kid to be checked against the signing key only")
+	}
+}
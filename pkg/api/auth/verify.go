@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"gorm.io/gorm"
+	"time"
+)
+
+const EmailVerificationTTL = time.Hour * 24 * 7
+
+var ErrVerificationInvalid = &apierror.Error{Code: apierror.CodeValidation, Message: "email verification token invalid"}
+
+// CreateEmailVerification issues a token proving that a user receives mail at
+// an address, either the one they signed up with or one they're changing to.
+func CreateEmailVerification(db *database.DB, userID int, email string) (string, error) {
+	secret, hash, err := Secret()
+	if err != nil {
+		return "", fmt.Errorf("verification token error: %w", err)
+	}
+	now := time.Now()
+	// Only the latest token may be used, so that one for an address the user
+	// has since changed can't verify it.
+	err = db.Model(&database.EmailVerification{}).
+		Where("user_id = ? AND used IS NULL AND expires > ?", userID, now).
+		Update("expires", now).Error
+	if err != nil {
+		return "", fmt.Errorf("database error: %w", err)
+	}
+	verification := database.EmailVerification{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: hash,
+		Created:   now,
+		Expires:   now.Add(EmailVerificationTTL),
+	}
+	if err := db.Create(&verification).Error; err != nil {
+		return "", fmt.Errorf("database error: %w", err)
+	}
+	return secret, nil
+}
+
+// VerifyEmail consumes a verification token, making its address the user's
+// verified address.
+func VerifyEmail(db *database.DB, token string) (int, error) {
+	var userID int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var verification database.EmailVerification
+		if err := tx.Where(&database.EmailVerification{TokenHash: SecretHash(token)}).First(&verification).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVerificationInvalid
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		now := time.Now()
+		if verification.Used != nil || !now.Before(verification.Expires) {
+			return ErrVerificationInvalid
+		}
+		res := tx.Model(&verification).Where("used IS NULL").Update("used", now)
+		if res.Error != nil {
+			return fmt.Errorf("database error: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return ErrVerificationInvalid
+		}
+		user := database.User{ID: verification.UserID}
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":          verification.Email,
+			"email_verified": now,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		userID = verification.UserID
+		return nil
+	})
+	return userID, err
+}
+
+// EmailVerified reports whether a user has verified their address.
+func EmailVerified(db *database.DB, userID int) (bool, error) {
+	var user database.User
+	if err := db.Select("email_verified").Where(&database.User{ID: userID}).First(&user).Error; err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+	return user.EmailVerified != nil, nil
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"time"
+)
+
+const DefaultReconcileInterval = time.Hour
+
+// ReconcileLikeCounts sets the like count of every rule that disagrees with its
+// likes and returns how many were repaired.
+func ReconcileLikeCounts(db *DB) (int64, error) {
+	var repaired int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			// Wait for likes being changed to commit, and hold off new changes,
+			// so that no count is repaired from likes that are about to change.
+			if err := tx.Exec("LOCK TABLE likes IN SHARE MODE").Error; err != nil {
+				return err
+			}
+		}
+		res := tx.Exec(`UPDATE rules
+			SET like_count = (SELECT COUNT(*) FROM likes WHERE likes.rule_id = rules.id)
+			WHERE like_count <> (SELECT COUNT(*) FROM likes WHERE likes.rule_id = rules.id)`)
+		repaired = res.RowsAffected
+		return res.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+	return repaired, nil
+}
+
+// ReconcileLikeCountsEvery reconciles like counts every interval until ctx is
+// done.
+func ReconcileLikeCountsEvery(ctx context.Context, db *DB, interval time.Duration) {
+	if interval == 0 {
+		interval = DefaultReconcileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		repaired, err := ReconcileLikeCounts(db.WithContext(ctx))
+		if err != nil {
+			log.Printf("like count reconcile error: %v", err)
+		} else if repaired != 0 {
+			log.Printf("repaired %d like counts", repaired)
+		}
+	}
+}
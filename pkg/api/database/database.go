@@ -1,24 +1,68 @@
 package database
 
 import (
+	"fmt"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"strings"
 )
 
 var DefaultDSN = "host=localhost user=dictator password=dictator dbname=dictator sslmode=disable"
 
 type DB = gorm.DB
 
+// Dialector selects a driver from the DSN scheme. postgres:// and
+// postgresql:// URLs and key=value connection strings open Postgres, while
+// sqlite://<path> and file:<path> open SQLite, e.g. sqlite://:memory: for an
+// in-memory database.
+func Dialector(dsn string) gorm.Dialector {
+	switch {
+	case strings.HasPrefix(dsn, "sqlite://"):
+		return sqlite.Open(sqliteDSN(strings.TrimPrefix(dsn, "sqlite://")))
+	case strings.HasPrefix(dsn, "file:"):
+		return sqlite.Open(sqliteDSN(dsn))
+	default:
+		return postgres.Open(dsn)
+	}
+}
+
+// SQLite doesn't enforce foreign keys unless asked to on every connection.
+func sqliteDSN(dsn string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_foreign_keys=on"
+}
+
 func Open(dsn string) (*DB, error) {
 	if dsn == "" {
 		dsn = DefaultDSN
 	}
-	return gorm.Open(postgres.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(Dialector(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
+	if err != nil {
+		return nil, err
+	}
+	if db.Dialector.Name() == "sqlite" {
+		// SQLite serializes writers anyway, and an in-memory database only
+		// exists for as long as its one connection does.
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("sqlite connection error: %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+	return db, nil
 }
 
+// Migrate applies any pending schema migrations.
 func Migrate(db *DB) error {
-	return db.AutoMigrate(&User{}, &Rule{}, &Like{})
+	_, err := MigrateUp(db)
+	return err
 }
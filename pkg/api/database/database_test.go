@@ -0,0 +1,32 @@
+package database
+
+import "testing"
+
+func TestDialector(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"postgres://dictator@localhost/dictator":   "postgres",
+		"postgresql://dictator@localhost/dictator": "postgres",
+		DefaultDSN:             "postgres",
+		"sqlite://:memory:":    "sqlite",
+		"sqlite://dev.db":      "sqlite",
+		"file:dev.db?mode=rwc": "sqlite",
+	} {
+		if got := Dialector(dsn).Name(); got != want {
+			t.Errorf("%s: expected %s, got %s", dsn, want, got)
+		}
+	}
+}
+
+func TestOpenSQLiteEnforcesForeignKeys(t *testing.T) {
+	db, err := Open("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var enabled int
+	if err := db.Raw("PRAGMA foreign_keys").Scan(&enabled).Error; err != nil {
+		t.Fatal(err)
+	}
+	if enabled != 1 {
+		t.Error("expected foreign keys to be enforced")
+	}
+}
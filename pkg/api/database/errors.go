@@ -0,0 +1,44 @@
+package database
+
+import (
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"regexp"
+	"strings"
+)
+
+// pgUniqueKey matches the key in the detail of a Postgres unique violation,
+// e.g. Key (email)=(a@example.com) already exists.
+var pgUniqueKey = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// UniqueViolation returns the columns of the unique constraint that err
+// violates, if it's a unique violation.
+func UniqueViolation(err error) ([]string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Code != "23505" {
+			return nil, false
+		}
+		if m := pgUniqueKey.FindStringSubmatch(pgErr.Detail); m != nil {
+			return strings.Split(m[1], ", "), true
+		}
+		return nil, true
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		if sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique && sqliteErr.ExtendedCode != sqlite3.ErrConstraintPrimaryKey {
+			return nil, false
+		}
+		// e.g. UNIQUE constraint failed: users.email
+		_, cols, _ := strings.Cut(sqliteErr.Error(), "constraint failed: ")
+		var columns []string
+		for _, col := range strings.Split(cols, ", ") {
+			if _, name, ok := strings.Cut(col, "."); ok {
+				columns = append(columns, name)
+			}
+		}
+		return columns, true
+	}
+	return nil, false
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations
+var migrationFS embed.FS
+
+// migrationLockKey identifies the Postgres advisory lock held while migrating
+// so that replicas starting together don't apply the same migration twice.
+const migrationLockKey = 0x62656e6564696374 // "benedict"
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type SchemaMigration struct {
+	Version int       `gorm:"primaryKey;not null"`
+	Name    string    `gorm:"not null"`
+	Applied time.Time `gorm:"not null"`
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied *time.Time
+}
+
+// Migrations returns the embedded migrations for a dialect in version order.
+func Migrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %s: %w", dialect, err)
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		b, err := fs.ReadFile(migrationFS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has names %s and %s", version, m.Name, match[2])
+		}
+		switch match[3] {
+		case "up":
+			m.Up = string(b)
+		case "down":
+			m.Down = string(b)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// withMigrationLock runs f on a single connection holding the migration lock.
+func withMigrationLock(db *DB, f func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		if conn.Dialector.Name() == "postgres" {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+				return fmt.Errorf("migration lock error: %w", err)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey)
+		}
+		if err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version integer NOT NULL PRIMARY KEY,
+			name text NOT NULL,
+			applied timestamp NOT NULL
+		)`).Error; err != nil {
+			return fmt.Errorf("create schema_migrations error: %w", err)
+		}
+		return f(conn)
+	})
+}
+
+func appliedMigrations(conn *gorm.DB) (map[int]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := conn.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("read schema_migrations error: %w", err)
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// MigrateUp applies every pending migration, each in its own transaction.
+func MigrateUp(db *DB) ([]Migration, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{
+					Version: m.Version,
+					Name:    m.Name,
+					Applied: time.Now(),
+				}).Error
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s up error: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown reverts the most recently applied migrations, at most steps.
+func MigrateDown(db *DB, steps int) ([]Migration, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s is irreversible", m.Version, m.Name)
+			}
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{Version: m.Version}).Error
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s down error: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationStatuses lists every known migration with when it was applied.
+func MigrationStatuses(db *DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			status := MigrationStatus{Migration: m}
+			if row, ok := applied[m.Version]; ok {
+				status.Applied = &row.Applied
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
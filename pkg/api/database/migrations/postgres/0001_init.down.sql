@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS likes;
+DROP TABLE IF EXISTS rules;
+DROP TABLE IF EXISTS refresh_rotations;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
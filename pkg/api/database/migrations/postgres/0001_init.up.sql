@@ -0,0 +1,53 @@
+-- Matches the schema previously created by gorm AutoMigrate, so existing
+-- databases can adopt versioned migrations without changes.
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial NOT NULL,
+    name text NOT NULL,
+    email text NOT NULL,
+    salt bytea NOT NULL,
+    key bytea NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (name),
+    UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id bigserial NOT NULL,
+    user_id bigint NOT NULL,
+    created timestamptz NOT NULL,
+    expires timestamptz NOT NULL,
+    revoked timestamptz,
+    refresh_hash bytea NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+-- Refresh tokens rotated out of sessions, so that presenting one again can be
+-- told apart from presenting a token that was never issued.
+CREATE TABLE IF NOT EXISTS refresh_rotations (
+    refresh_hash bytea NOT NULL,
+    session_id bigint NOT NULL,
+    rotated timestamptz NOT NULL,
+    PRIMARY KEY (refresh_hash),
+    CONSTRAINT fk_refresh_rotations_session FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_rotations_session_id ON refresh_rotations (session_id);
+
+CREATE TABLE IF NOT EXISTS rules (
+    id bigserial NOT NULL,
+    user_id bigint NOT NULL,
+    created timestamptz NOT NULL,
+    summary text NOT NULL,
+    detail text,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_rules_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS likes (
+    user_id bigint NOT NULL,
+    rule_id bigint NOT NULL,
+    PRIMARY KEY (user_id, rule_id),
+    CONSTRAINT fk_likes_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_likes_rule FOREIGN KEY (rule_id) REFERENCES rules (id)
+);
@@ -0,0 +1,26 @@
+CREATE TABLE password_resets (
+    id bigserial NOT NULL,
+    user_id bigint NOT NULL,
+    token_hash bytea NOT NULL,
+    created timestamptz NOT NULL,
+    expires timestamptz NOT NULL,
+    used timestamptz,
+    PRIMARY KEY (id),
+    UNIQUE (token_hash),
+    CONSTRAINT fk_password_resets_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX idx_password_resets_user_id ON password_resets (user_id);
+
+CREATE TABLE mail_outbox (
+    id bigserial NOT NULL,
+    recipient text NOT NULL,
+    subject text NOT NULL,
+    body text NOT NULL,
+    created timestamptz NOT NULL,
+    next_attempt timestamptz NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text,
+    sent timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_mail_outbox_pending ON mail_outbox (next_attempt) WHERE sent IS NULL;
@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN email_verified timestamptz;
+-- Accounts created before verification existed keep working.
+UPDATE users SET email_verified = now();
+
+CREATE TABLE email_verifications (
+    id bigserial NOT NULL,
+    user_id bigint NOT NULL,
+    email text NOT NULL,
+    token_hash bytea NOT NULL,
+    created timestamptz NOT NULL,
+    expires timestamptz NOT NULL,
+    used timestamptz,
+    PRIMARY KEY (id),
+    UNIQUE (token_hash),
+    CONSTRAINT fk_email_verifications_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX idx_email_verifications_user_id ON email_verifications (user_id);
@@ -0,0 +1,2 @@
+DROP TABLE rule_revisions;
+ALTER TABLE rules DROP COLUMN updated;
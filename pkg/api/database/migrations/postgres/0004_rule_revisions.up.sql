@@ -0,0 +1,19 @@
+ALTER TABLE rules ADD COLUMN updated timestamptz;
+
+CREATE TABLE rule_revisions (
+    id bigserial NOT NULL,
+    rule_id bigint NOT NULL,
+    number integer NOT NULL,
+    editor_id bigint NOT NULL,
+    created timestamptz NOT NULL,
+    summary text NOT NULL,
+    detail text,
+    PRIMARY KEY (id),
+    UNIQUE (rule_id, number),
+    CONSTRAINT fk_rule_revisions_rule FOREIGN KEY (rule_id) REFERENCES rules (id) ON DELETE CASCADE,
+    CONSTRAINT fk_rule_revisions_editor FOREIGN KEY (editor_id) REFERENCES users (id)
+);
+
+-- Every rule starts with its original text as revision 1.
+INSERT INTO rule_revisions (rule_id, number, editor_id, created, summary, detail)
+SELECT id, 1, user_id, created, summary, detail FROM rules;
@@ -0,0 +1,7 @@
+DROP INDEX idx_rules_status;
+ALTER TABLE rules DROP CONSTRAINT fk_rules_decider;
+ALTER TABLE rules DROP COLUMN decider_id;
+ALTER TABLE rules DROP COLUMN decided;
+ALTER TABLE rules DROP COLUMN voting_ends;
+ALTER TABLE rules DROP COLUMN status;
+ALTER TABLE users DROP COLUMN role;
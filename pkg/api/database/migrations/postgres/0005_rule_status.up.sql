@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN role text NOT NULL DEFAULT 'MEMBER';
+
+-- Rules made before proposals existed were binding as soon as they were made.
+ALTER TABLE rules ADD COLUMN status text NOT NULL DEFAULT 'RATIFIED';
+ALTER TABLE rules ALTER COLUMN status SET DEFAULT 'PROPOSED';
+ALTER TABLE rules ADD COLUMN voting_ends timestamptz;
+ALTER TABLE rules ADD COLUMN decided timestamptz;
+ALTER TABLE rules ADD COLUMN decider_id bigint;
+ALTER TABLE rules ADD CONSTRAINT fk_rules_decider FOREIGN KEY (decider_id) REFERENCES users (id);
+CREATE INDEX idx_rules_status ON rules (status);
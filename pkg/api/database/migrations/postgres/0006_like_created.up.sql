@@ -0,0 +1,5 @@
+-- Likes made before their time was recorded count from when their rule was made.
+ALTER TABLE likes ADD COLUMN created timestamptz;
+UPDATE likes SET created = rules.created FROM rules WHERE rules.id = likes.rule_id;
+ALTER TABLE likes ALTER COLUMN created SET NOT NULL;
+CREATE INDEX idx_likes_rule_created ON likes (rule_id, created);
@@ -0,0 +1,2 @@
+DROP INDEX idx_rules_search;
+ALTER TABLE rules DROP COLUMN search;
@@ -0,0 +1,6 @@
+-- Summary matches rank above detail matches.
+ALTER TABLE rules ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', summary), 'A') ||
+    setweight(to_tsvector('english', COALESCE(detail, '')), 'B')
+) STORED;
+CREATE INDEX idx_rules_search ON rules USING GIN (search);
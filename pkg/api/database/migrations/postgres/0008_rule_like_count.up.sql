@@ -0,0 +1,3 @@
+ALTER TABLE rules ADD COLUMN like_count integer NOT NULL DEFAULT 0;
+UPDATE rules SET like_count = (SELECT COUNT(*) FROM likes WHERE likes.rule_id = rules.id);
+CREATE INDEX idx_rules_like_count ON rules (like_count, id);
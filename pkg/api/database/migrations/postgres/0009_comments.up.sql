@@ -0,0 +1,17 @@
+-- Deleted comments keep their place in a thread with their body cleared.
+CREATE TABLE comments (
+    id bigserial NOT NULL,
+    rule_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    parent_id bigint,
+    created timestamptz NOT NULL,
+    updated timestamptz,
+    deleted timestamptz,
+    body text NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_comments_rule FOREIGN KEY (rule_id) REFERENCES rules (id) ON DELETE CASCADE,
+    CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_comments_parent FOREIGN KEY (parent_id) REFERENCES comments (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_comments_rule_id ON comments (rule_id, id);
+CREATE INDEX idx_comments_parent_id ON comments (parent_id, id);
@@ -0,0 +1,2 @@
+DROP TABLE rule_tags;
+DROP TABLE tags;
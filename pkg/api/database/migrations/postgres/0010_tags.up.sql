@@ -0,0 +1,15 @@
+CREATE TABLE tags (
+    id bigserial NOT NULL,
+    name text NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE (name)
+);
+
+CREATE TABLE rule_tags (
+    rule_id bigint NOT NULL,
+    tag_id bigint NOT NULL,
+    PRIMARY KEY (rule_id, tag_id),
+    CONSTRAINT fk_rule_tags_rule FOREIGN KEY (rule_id) REFERENCES rules (id) ON DELETE CASCADE,
+    CONSTRAINT fk_rule_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_rule_tags_tag_id ON rule_tags (tag_id);
@@ -0,0 +1,10 @@
+CREATE TABLE follows (
+    follower_id bigint NOT NULL,
+    followee_id bigint NOT NULL,
+    created timestamptz NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CONSTRAINT fk_follows_follower FOREIGN KEY (follower_id) REFERENCES users (id),
+    CONSTRAINT fk_follows_followee FOREIGN KEY (followee_id) REFERENCES users (id),
+    CHECK (follower_id <> followee_id)
+);
+CREATE INDEX idx_follows_followee_id ON follows (followee_id);
@@ -0,0 +1,29 @@
+-- Events of the same kind about the same subject, either a liked rule or the
+-- author of new rules, are coalesced into one notification until it's read.
+CREATE TABLE notifications (
+    id bigserial NOT NULL,
+    user_id bigint NOT NULL,
+    kind text NOT NULL,
+    subject_id bigint NOT NULL,
+    rule_id bigint NOT NULL,
+    actor_id bigint NOT NULL,
+    event_count integer NOT NULL,
+    created timestamptz NOT NULL,
+    updated timestamptz NOT NULL,
+    read timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_notifications_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_notifications_rule FOREIGN KEY (rule_id) REFERENCES rules (id) ON DELETE CASCADE,
+    CONSTRAINT fk_notifications_actor FOREIGN KEY (actor_id) REFERENCES users (id)
+);
+CREATE INDEX idx_notifications_user_id ON notifications (user_id, updated, id);
+CREATE UNIQUE INDEX idx_notifications_unread ON notifications (user_id, kind, subject_id) WHERE read IS NULL;
+
+-- Users get every kind of notification unless they turn it off.
+CREATE TABLE notification_preferences (
+    user_id bigint NOT NULL,
+    kind text NOT NULL,
+    enabled boolean NOT NULL,
+    PRIMARY KEY (user_id, kind),
+    CONSTRAINT fk_notification_preferences_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
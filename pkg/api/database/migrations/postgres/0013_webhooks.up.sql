@@ -0,0 +1,27 @@
+-- events is a comma-separated list of the events a webhook subscribes to.
+CREATE TABLE webhooks (
+    id bigserial NOT NULL,
+    url text NOT NULL,
+    secret text NOT NULL,
+    events text NOT NULL,
+    active boolean NOT NULL,
+    created timestamptz NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE webhook_deliveries (
+    id bigserial NOT NULL,
+    webhook_id bigint NOT NULL,
+    event text NOT NULL,
+    payload text NOT NULL,
+    created timestamptz NOT NULL,
+    next_attempt timestamptz NOT NULL,
+    attempts integer NOT NULL,
+    last_status integer,
+    last_error text,
+    delivered timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_webhook_deliveries_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id);
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt) WHERE delivered IS NULL;
@@ -0,0 +1,18 @@
+-- Failed logins are counted per email rather than per user, so that unknown
+-- emails are locked out the same as known ones.
+CREATE TABLE login_failures (
+    email text NOT NULL,
+    failures integer NOT NULL,
+    locked_until timestamptz,
+    updated timestamptz NOT NULL,
+    PRIMARY KEY (email)
+);
+CREATE INDEX idx_login_failures_updated ON login_failures (updated);
+
+CREATE TABLE rate_limits (
+    key text NOT NULL,
+    tokens double precision NOT NULL,
+    updated timestamptz NOT NULL,
+    PRIMARY KEY (key)
+);
+CREATE INDEX idx_rate_limits_updated ON rate_limits (updated);
@@ -0,0 +1,5 @@
+DROP TABLE likes;
+DROP TABLE rules;
+DROP TABLE refresh_rotations;
+DROP TABLE sessions;
+DROP TABLE users;
@@ -0,0 +1,40 @@
+CREATE TABLE users (
+    id integer NOT NULL PRIMARY KEY,
+    name text NOT NULL UNIQUE,
+    email text NOT NULL UNIQUE,
+    salt blob NOT NULL,
+    key blob NOT NULL
+);
+
+CREATE TABLE sessions (
+    id integer NOT NULL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users (id),
+    created datetime NOT NULL,
+    expires datetime NOT NULL,
+    revoked datetime,
+    refresh_hash blob NOT NULL
+);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+-- Refresh tokens rotated out of sessions, so that presenting one again can be
+-- told apart from presenting a token that was never issued.
+CREATE TABLE refresh_rotations (
+    refresh_hash blob NOT NULL PRIMARY KEY,
+    session_id integer NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    rotated datetime NOT NULL
+);
+CREATE INDEX idx_refresh_rotations_session_id ON refresh_rotations (session_id);
+
+CREATE TABLE rules (
+    id integer NOT NULL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users (id),
+    created datetime NOT NULL,
+    summary text NOT NULL,
+    detail text
+);
+
+CREATE TABLE likes (
+    user_id integer NOT NULL REFERENCES users (id),
+    rule_id integer NOT NULL REFERENCES rules (id),
+    PRIMARY KEY (user_id, rule_id)
+);
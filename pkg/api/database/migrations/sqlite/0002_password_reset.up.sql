@@ -0,0 +1,22 @@
+CREATE TABLE password_resets (
+    id integer NOT NULL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users (id),
+    token_hash blob NOT NULL UNIQUE,
+    created datetime NOT NULL,
+    expires datetime NOT NULL,
+    used datetime
+);
+CREATE INDEX idx_password_resets_user_id ON password_resets (user_id);
+
+CREATE TABLE mail_outbox (
+    id integer NOT NULL PRIMARY KEY,
+    recipient text NOT NULL,
+    subject text NOT NULL,
+    body text NOT NULL,
+    created datetime NOT NULL,
+    next_attempt datetime NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text,
+    sent datetime
+);
+CREATE INDEX idx_mail_outbox_pending ON mail_outbox (next_attempt) WHERE sent IS NULL;
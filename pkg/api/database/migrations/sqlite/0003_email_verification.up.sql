@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN email_verified datetime;
+-- Accounts created before verification existed keep working.
+UPDATE users SET email_verified = CURRENT_TIMESTAMP;
+
+CREATE TABLE email_verifications (
+    id integer NOT NULL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users (id),
+    email text NOT NULL,
+    token_hash blob NOT NULL UNIQUE,
+    created datetime NOT NULL,
+    expires datetime NOT NULL,
+    used datetime
+);
+CREATE INDEX idx_email_verifications_user_id ON email_verifications (user_id);
@@ -0,0 +1,16 @@
+ALTER TABLE rules ADD COLUMN updated datetime;
+
+CREATE TABLE rule_revisions (
+    id integer NOT NULL PRIMARY KEY,
+    rule_id integer NOT NULL REFERENCES rules (id) ON DELETE CASCADE,
+    number integer NOT NULL,
+    editor_id integer NOT NULL REFERENCES users (id),
+    created datetime NOT NULL,
+    summary text NOT NULL,
+    detail text,
+    UNIQUE (rule_id, number)
+);
+
+-- Every rule starts with its original text as revision 1.
+INSERT INTO rule_revisions (rule_id, number, editor_id, created, summary, detail)
+SELECT id, 1, user_id, created, summary, detail FROM rules;
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN role text NOT NULL DEFAULT 'MEMBER';
+
+-- Rules made before proposals existed were binding as soon as they were made.
+ALTER TABLE rules ADD COLUMN status text NOT NULL DEFAULT 'RATIFIED';
+ALTER TABLE rules ADD COLUMN voting_ends datetime;
+ALTER TABLE rules ADD COLUMN decided datetime;
+-- No foreign key as SQLite can't drop a column that has one.
+ALTER TABLE rules ADD COLUMN decider_id integer;
+CREATE INDEX idx_rules_status ON rules (status);
@@ -0,0 +1,2 @@
+DROP INDEX idx_likes_rule_created;
+ALTER TABLE likes DROP COLUMN created;
@@ -0,0 +1,4 @@
+-- Likes made before their time was recorded count from when their rule was made.
+ALTER TABLE likes ADD COLUMN created datetime NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+UPDATE likes SET created = (SELECT created FROM rules WHERE rules.id = likes.rule_id);
+CREATE INDEX idx_likes_rule_created ON likes (rule_id, created);
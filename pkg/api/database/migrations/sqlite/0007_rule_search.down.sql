@@ -0,0 +1 @@
+-- SQLite has no tsvector, so rules are searched by matching words with LIKE.
@@ -0,0 +1,2 @@
+DROP INDEX idx_rules_like_count;
+ALTER TABLE rules DROP COLUMN like_count;
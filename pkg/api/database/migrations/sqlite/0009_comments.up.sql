@@ -0,0 +1,13 @@
+-- Deleted comments keep their place in a thread with their body cleared.
+CREATE TABLE comments (
+    id integer NOT NULL PRIMARY KEY,
+    rule_id integer NOT NULL REFERENCES rules (id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users (id),
+    parent_id integer REFERENCES comments (id) ON DELETE CASCADE,
+    created datetime NOT NULL,
+    updated datetime,
+    deleted datetime,
+    body text NOT NULL
+);
+CREATE INDEX idx_comments_rule_id ON comments (rule_id, id);
+CREATE INDEX idx_comments_parent_id ON comments (parent_id, id);
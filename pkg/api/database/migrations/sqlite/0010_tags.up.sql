@@ -0,0 +1,11 @@
+CREATE TABLE tags (
+    id integer NOT NULL PRIMARY KEY,
+    name text NOT NULL UNIQUE
+);
+
+CREATE TABLE rule_tags (
+    rule_id integer NOT NULL REFERENCES rules (id) ON DELETE CASCADE,
+    tag_id integer NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (rule_id, tag_id)
+);
+CREATE INDEX idx_rule_tags_tag_id ON rule_tags (tag_id);
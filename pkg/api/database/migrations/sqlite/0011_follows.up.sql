@@ -0,0 +1,8 @@
+CREATE TABLE follows (
+    follower_id integer NOT NULL REFERENCES users (id),
+    followee_id integer NOT NULL REFERENCES users (id),
+    created datetime NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+CREATE INDEX idx_follows_followee_id ON follows (followee_id);
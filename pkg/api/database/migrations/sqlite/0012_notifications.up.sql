@@ -0,0 +1,24 @@
+-- Events of the same kind about the same subject, either a liked rule or the
+-- author of new rules, are coalesced into one notification until it's read.
+CREATE TABLE notifications (
+    id integer NOT NULL PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users (id),
+    kind text NOT NULL,
+    subject_id integer NOT NULL,
+    rule_id integer NOT NULL REFERENCES rules (id) ON DELETE CASCADE,
+    actor_id integer NOT NULL REFERENCES users (id),
+    event_count integer NOT NULL,
+    created datetime NOT NULL,
+    updated datetime NOT NULL,
+    read datetime
+);
+CREATE INDEX idx_notifications_user_id ON notifications (user_id, updated, id);
+CREATE UNIQUE INDEX idx_notifications_unread ON notifications (user_id, kind, subject_id) WHERE read IS NULL;
+
+-- Users get every kind of notification unless they turn it off.
+CREATE TABLE notification_preferences (
+    user_id integer NOT NULL REFERENCES users (id),
+    kind text NOT NULL,
+    enabled boolean NOT NULL,
+    PRIMARY KEY (user_id, kind)
+);
@@ -0,0 +1,24 @@
+-- events is a comma-separated list of the events a webhook subscribes to.
+CREATE TABLE webhooks (
+    id integer NOT NULL PRIMARY KEY,
+    url text NOT NULL,
+    secret text NOT NULL,
+    events text NOT NULL,
+    active boolean NOT NULL,
+    created datetime NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+    id integer NOT NULL PRIMARY KEY,
+    webhook_id integer NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event text NOT NULL,
+    payload text NOT NULL,
+    created datetime NOT NULL,
+    next_attempt datetime NOT NULL,
+    attempts integer NOT NULL,
+    last_status integer,
+    last_error text,
+    delivered datetime
+);
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id);
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt) WHERE delivered IS NULL;
@@ -0,0 +1,16 @@
+-- Failed logins are counted per email rather than per user, so that unknown
+-- emails are locked out the same as known ones.
+CREATE TABLE login_failures (
+    email text NOT NULL PRIMARY KEY,
+    failures integer NOT NULL,
+    locked_until datetime,
+    updated datetime NOT NULL
+);
+CREATE INDEX idx_login_failures_updated ON login_failures (updated);
+
+CREATE TABLE rate_limits (
+    key text NOT NULL PRIMARY KEY,
+    tokens real NOT NULL,
+    updated datetime NOT NULL
+);
+CREATE INDEX idx_rate_limits_updated ON rate_limits (updated);
@@ -5,114 +5,255 @@ import (
 	"time"
 )
 
+// Roles in increasing order of privilege. Each role may do anything the roles
+// before it may.
+const (
+	RoleMember    = "MEMBER"
+	RoleModerator = "MODERATOR"
+	RoleAdmin     = "ADMIN"
+	RoleDictator  = "DICTATOR"
+)
+
+var Roles = []string{RoleMember, RoleModerator, RoleAdmin, RoleDictator}
+
 type User struct {
-	ID    int    `gorm:"primaryKey;not null"`
-	Name  string `gorm:"unique;not null"`
-	Email string `gorm:"unique;not null"`
-	Salt  []byte `gorm:"not null"`
-	Key   []byte `gorm:"not null"`
-	Likes []Rule `gorm:"many2many:likes"`
+	ID            int    `gorm:"primaryKey;not null"`
+	Name          string `gorm:"unique;not null"`
+	Email         string `gorm:"unique;not null"`
+	EmailVerified *time.Time
+	Role          string `gorm:"not null"`
+	Salt          []byte `gorm:"not null"`
+	Key           []byte `gorm:"not null"`
+	Likes         []Rule `gorm:"many2many:likes"`
 }
 
-func (u User) IDRef() *int {
-	return &u.ID
+func (u User) Item() *User {
+	return &u
 }
 
-func (u User) IDAfter() func(*gorm.DB) *gorm.DB {
+func (u User) NameLike() func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		return db.Model(&u).Where("id > ?", u.ID)
+		return db.Model(&u).Where("name LIKE ?", u.Name)
 	}
 }
 
-func (u User) IDBeforeOrEqual() func(*gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		return db.Model(&u).Where("id <= ?", u.ID)
-	}
+type Session struct {
+	ID          int `gorm:"primaryKey;not null"`
+	UserID      int `gorm:"not null;index"`
+	User        *User
+	Created     time.Time `gorm:"not null"`
+	Expires     time.Time `gorm:"not null"`
+	Revoked     *time.Time
+	RefreshHash []byte `gorm:"not null"`
 }
 
-func (u User) Item() *User {
-	return &u
+// RefreshRotation is a refresh token that has been rotated out of a session.
+type RefreshRotation struct {
+	RefreshHash []byte    `gorm:"primaryKey;not null"`
+	SessionID   int       `gorm:"not null;index"`
+	Rotated     time.Time `gorm:"not null"`
 }
 
-func (u User) NameLike() func(db *gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		return db.Model(&u).Where("name LIKE ?", u.Name)
-	}
+type PasswordReset struct {
+	ID        int `gorm:"primaryKey;not null"`
+	UserID    int `gorm:"not null;index"`
+	User      *User
+	TokenHash []byte    `gorm:"unique;not null"`
+	Created   time.Time `gorm:"not null"`
+	Expires   time.Time `gorm:"not null"`
+	Used      *time.Time
 }
 
-type Rule struct {
-	ID      int `gorm:"primaryKey;not null"`
-	UserID  int `gorm:"not null"` // TODO: Rename to UserID
-	User    *User
-	Created time.Time `gorm:"not null"`
-	Summary string    `gorm:"not null"`
-	Detail  *string
-	Likes   []User `gorm:"many2many:likes"`
+// EmailVerification confirms that a user receives mail at Email, which
+// becomes their address once verified.
+type EmailVerification struct {
+	ID        int `gorm:"primaryKey;not null"`
+	UserID    int `gorm:"not null;index"`
+	User      *User
+	Email     string    `gorm:"not null"`
+	TokenHash []byte    `gorm:"unique;not null"`
+	Created   time.Time `gorm:"not null"`
+	Expires   time.Time `gorm:"not null"`
+	Used      *time.Time
 }
 
-func (r Rule) IDRef() *int {
-	return &r.ID
+type OutboxMail struct {
+	ID          int       `gorm:"primaryKey;not null"`
+	Recipient   string    `gorm:"not null"`
+	Subject     string    `gorm:"not null"`
+	Body        string    `gorm:"not null"`
+	Created     time.Time `gorm:"not null"`
+	NextAttempt time.Time `gorm:"not null"`
+	Attempts    int       `gorm:"not null"`
+	LastError   *string
+	Sent        *time.Time
 }
 
-func (r Rule) IDAfter() func(*gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		return db.Model(&r).Where("id > ?", r.ID)
-	}
+func (m OutboxMail) TableName() string {
+	return "mail_outbox"
 }
 
-func (r Rule) IDBeforeOrEqual() func(*gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		return db.Model(&r).Where("id <= ?", r.ID)
-	}
+// A rule is proposed, and once voting has ended the dictator either ratifies
+// it, making it binding until it is repealed, or vetoes it. The dictator may
+// veto a proposal before voting ends too.
+const (
+	RuleProposed = "PROPOSED"
+	RuleRatified = "RATIFIED"
+	RuleVetoed   = "VETOED"
+	RuleRepealed = "REPEALED"
+)
+
+type Rule struct {
+	ID         int `gorm:"primaryKey;not null"`
+	UserID     int `gorm:"not null"` // TODO: Rename to UserID
+	User       *User
+	Created    time.Time `gorm:"not null"`
+	Updated    *time.Time
+	Summary    string `gorm:"not null"`
+	Detail     *string
+	Status     string `gorm:"not null"`
+	VotingEnds *time.Time
+	Decided    *time.Time
+	DeciderID  *int
+	Decider    *User
+	Likes      []User `gorm:"many2many:likes"`
+	Tags       []Tag  `gorm:"many2many:rule_tags"`
+	// LikeCount counts the rule's likes. It changes along with them, and
+	// ReconcileLikeCounts repairs it should the two ever disagree.
+	LikeCount int `gorm:"not null"`
+}
+
+// RuleRevision is one version of a rule's text. Revision 1 is the text the
+// rule was created with and the highest numbered revision is its current text.
+type RuleRevision struct {
+	ID       int `gorm:"primaryKey;not null"`
+	RuleID   int `gorm:"not null"`
+	Rule     *Rule
+	Number   int `gorm:"not null"`
+	EditorID int `gorm:"not null"`
+	Editor   *User
+	Created  time.Time `gorm:"not null"`
+	Summary  string    `gorm:"not null"`
+	Detail   *string
 }
 
 type Like struct {
-	UserID int `gorm:"primaryKey;not null"`
-	User   *User
-	RuleID int `gorm:"primaryKey;not null"`
-	Rule   *Rule
+	UserID  int `gorm:"primaryKey;not null"`
+	User    *User
+	RuleID  int `gorm:"primaryKey;not null"`
+	Rule    *Rule
+	Created time.Time `gorm:"not null"`
 }
 
-type UserLike Like
+// Follow is a user following another user's rules.
+type Follow struct {
+	FollowerID int `gorm:"primaryKey;not null"`
+	Follower   *User
+	FolloweeID int `gorm:"primaryKey;not null"`
+	Followee   *User
+	Created    time.Time `gorm:"not null"`
+}
 
-func (l UserLike) TableName() string {
-	return "likes"
+type Tag struct {
+	ID   int    `gorm:"primaryKey;not null"`
+	Name string `gorm:"unique;not null"`
 }
 
-func (l UserLike) IDRef() *int {
-	return &l.UserID
+type RuleTag struct {
+	RuleID int `gorm:"primaryKey;not null"`
+	Rule   *Rule
+	TagID  int `gorm:"primaryKey;not null"`
+	Tag    *Tag
 }
 
-func (l UserLike) IDAfter() func(*gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		return db.Model(&l).Where("user_id > ?", l.UserID)
-	}
+// Comment is a comment on a rule, or a reply to another comment if it has a
+// parent. A deleted comment's body is cleared but the comment remains so that
+// its replies still have a place in the thread.
+type Comment struct {
+	ID       int `gorm:"primaryKey;not null"`
+	RuleID   int `gorm:"not null"`
+	Rule     *Rule
+	UserID   int `gorm:"not null"`
+	User     *User
+	ParentID *int
+	Parent   *Comment
+	Created  time.Time `gorm:"not null"`
+	Updated  *time.Time
+	Deleted  *time.Time
+	Body     string `gorm:"not null"`
 }
 
-func (l UserLike) IDBeforeOrEqual() func(*gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		return db.Model(&l).Where("user_id <= ?", l.UserID)
-	}
+// Webhook posts the events it subscribes to to URL, signed with Secret.
+type Webhook struct {
+	ID      int       `gorm:"primaryKey;not null"`
+	URL     string    `gorm:"not null"`
+	Secret  string    `gorm:"not null"`
+	Events  string    `gorm:"not null"`
+	Active  bool      `gorm:"not null"`
+	Created time.Time `gorm:"not null"`
 }
 
-type RuleLike Like
+type WebhookDelivery struct {
+	ID          int `gorm:"primaryKey;not null"`
+	WebhookID   int `gorm:"not null"`
+	Webhook     *Webhook
+	Event       string    `gorm:"not null"`
+	Payload     string    `gorm:"not null"`
+	Created     time.Time `gorm:"not null"`
+	NextAttempt time.Time `gorm:"not null"`
+	Attempts    int       `gorm:"not null"`
+	LastStatus  *int
+	LastError   *string
+	Delivered   *time.Time
+}
+
+// Kinds of notification. A rule being liked notifies its author, and a rule
+// being created notifies the followers of its author.
+const (
+	NotificationRuleLiked   = "RULE_LIKED"
+	NotificationRuleCreated = "RULE_CREATED"
+)
+
+var NotificationKinds = []string{NotificationRuleLiked, NotificationRuleCreated}
 
-func (l RuleLike) TableName() string {
-	return "likes"
+// Notification tells a user about EventCount events of a kind about one
+// subject: the liked rule, or the author of the created rules. RuleID and
+// ActorID are those of the latest event.
+type Notification struct {
+	ID         int `gorm:"primaryKey;not null"`
+	UserID     int `gorm:"not null"`
+	User       *User
+	Kind       string `gorm:"not null"`
+	SubjectID  int    `gorm:"not null"`
+	RuleID     int    `gorm:"not null"`
+	Rule       *Rule
+	ActorID    int `gorm:"not null"`
+	Actor      *User
+	EventCount int       `gorm:"not null"`
+	Created    time.Time `gorm:"not null"`
+	Updated    time.Time `gorm:"not null"`
+	Read       *time.Time
 }
 
-func (l RuleLike) IDRef() *int {
-	return &l.RuleID
+type NotificationPreference struct {
+	UserID  int `gorm:"primaryKey;not null"`
+	User    *User
+	Kind    string `gorm:"primaryKey;not null"`
+	Enabled bool   `gorm:"not null"`
 }
 
-func (l RuleLike) IDAfter() func(*gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		return db.Model(&l).Where("rule_id > ?", l.RuleID)
-	}
+// LoginFailure counts failed logins to an email since the last successful
+// one, which lock the email out for longer and longer.
+type LoginFailure struct {
+	Email       string `gorm:"primaryKey;not null"`
+	Failures    int    `gorm:"not null"`
+	LockedUntil *time.Time
+	Updated     time.Time `gorm:"not null"`
 }
 
-func (l RuleLike) IDBeforeOrEqual() func(*gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		return db.Model(&l).Where("rule_id <= ?", l.RuleID)
-	}
+// RateLimit is a token bucket of a rate limit.
+type RateLimit struct {
+	Key     string    `gorm:"primaryKey;not null"`
+	Tokens  float64   `gorm:"not null"`
+	Updated time.Time `gorm:"not null"`
 }
@@ -0,0 +1,159 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+type Chunk struct {
+	Op   Op
+	Text string
+}
+
+var wordPattern = regexp.MustCompile(`\s+|\S+`)
+
+// Words diffs a and b word by word, keeping whitespace in the chunks so that
+// concatenating them gives back the original texts.
+func Words(a, b string) []Chunk {
+	return Tokens(wordPattern.FindAllString(a, -1), wordPattern.FindAllString(b, -1))
+}
+
+// Lines diffs a and b line by line.
+func Lines(a, b string) []Chunk {
+	return Tokens(splitLines(a), splitLines(b))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.SplitAfter(s, "\n")
+}
+
+// MaxWork caps the number of token comparisons made by Tokens. Inputs that
+// would need more are diffed as a whole-text replace instead.
+const MaxWork = 1 << 22
+
+// Tokens diffs two token sequences using their longest common subsequence,
+// merging runs of tokens with the same op into one chunk. It uses Hirschberg's
+// algorithm so that memory use is linear in the size of the input.
+func Tokens(a, b []string) []Chunk {
+	d := differ{}
+	// Common ends cost nothing to diff, so only the middle counts as work.
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	m := 0
+	for m < len(a)-n && m < len(b)-n && a[len(a)-1-m] == b[len(b)-1-m] {
+		m++
+	}
+	d.each(Equal, a[:n])
+	if x, y := len(a)-n-m, len(b)-n-m; x > 0 && y > 0 && x > MaxWork/y {
+		d.each(Delete, a[n:len(a)-m])
+		d.each(Insert, b[n:len(b)-m])
+	} else {
+		d.diff(a[n:len(a)-m], b[n:len(b)-m])
+	}
+	d.each(Equal, a[len(a)-m:])
+	return d.chunks
+}
+
+type differ struct {
+	chunks []Chunk
+}
+
+func (d *differ) add(op Op, text string) {
+	if n := len(d.chunks); n > 0 && d.chunks[n-1].Op == op {
+		d.chunks[n-1].Text += text
+		return
+	}
+	d.chunks = append(d.chunks, Chunk{Op: op, Text: text})
+}
+
+func (d *differ) each(op Op, tokens []string) {
+	for _, t := range tokens {
+		d.add(op, t)
+	}
+}
+
+func (d *differ) diff(a, b []string) {
+	switch {
+	case len(a) == 0:
+		d.each(Insert, b)
+		return
+	case len(b) == 0:
+		d.each(Delete, a)
+		return
+	case len(a) == 1:
+		for j := range b {
+			if b[j] == a[0] {
+				d.each(Insert, b[:j])
+				d.add(Equal, a[0])
+				d.each(Insert, b[j+1:])
+				return
+			}
+		}
+		d.add(Delete, a[0])
+		d.each(Insert, b)
+		return
+	}
+	// Split a in half and b where the LCS of the halves is longest.
+	mid := len(a) / 2
+	head := lcsHead(a[:mid], b)
+	tail := lcsTail(a[mid:], b)
+	k := 0
+	for j := range head {
+		if head[j]+tail[j] > head[k]+tail[k] {
+			k = j
+		}
+	}
+	d.diff(a[:mid], b[:k])
+	d.diff(a[mid:], b[k:])
+}
+
+// lcsHead returns the LCS lengths of a and each prefix b[:j].
+func lcsHead(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	row := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				row[j+1] = prev[j] + 1
+			} else if prev[j+1] >= row[j] {
+				row[j+1] = prev[j+1]
+			} else {
+				row[j+1] = row[j]
+			}
+		}
+		prev, row = row, prev
+	}
+	return prev
+}
+
+// lcsTail returns the LCS lengths of a and each suffix b[j:].
+func lcsTail(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	row := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				row[j] = prev[j+1] + 1
+			} else if prev[j] >= row[j+1] {
+				row[j] = prev[j]
+			} else {
+				row[j] = row[j+1]
+			}
+		}
+		prev, row = row, prev
+	}
+	return prev
+}
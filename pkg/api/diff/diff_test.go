@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want []Chunk
+	}{
+		{"", "", nil},
+		{"", "no tabs", []Chunk{{Insert, "no tabs"}}},
+		{"no tabs", "", []Chunk{{Delete, "no tabs"}}},
+		{"no tabs", "no tabs", []Chunk{{Equal, "no tabs"}}},
+		{"no tabs", "no tabs or spaces", []Chunk{{Equal, "no tabs"}, {Insert, " or spaces"}}},
+		{"the quick fox", "the slow fox", []Chunk{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " fox"}}},
+		{"a  b", "a b", []Chunk{{Equal, "a"}, {Delete, "  "}, {Insert, " "}, {Equal, "b"}}},
+	} {
+		if got := Words(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Words(%q, %q): expected %v, got %v", test.a, test.b, test.want, got)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want []Chunk
+	}{
+		{"", "", nil},
+		{"", "a\n", []Chunk{{Insert, "a\n"}}},
+		{"a\nb\nc\n", "a\nc\n", []Chunk{{Equal, "a\n"}, {Delete, "b\n"}, {Equal, "c\n"}}},
+		{"a\nc\n", "a\nb\nc\n", []Chunk{{Equal, "a\n"}, {Insert, "b\n"}, {Equal, "c\n"}}},
+		// A missing final newline makes for a different last line.
+		{"a\nb", "a\nb\n", []Chunk{{Equal, "a\n"}, {Delete, "b"}, {Insert, "b\n"}}},
+	} {
+		if got := Lines(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Lines(%q, %q): expected %v, got %v", test.a, test.b, test.want, got)
+		}
+	}
+}
+
+// TestTokensRebuild checks that the chunks of a diff give back both sides and
+// keep as many tokens as possible equal.
+func TestTokensRebuild(t *testing.T) {
+	a := strings.Split("a b c a b b a c b a b c", " ")
+	b := strings.Split("c b a b a c a b c b a", " ")
+	var from, to []string
+	equal := 0
+	for _, chunk := range Tokens(a, b) {
+		if chunk.Op != Insert {
+			from = append(from, chunk.Text)
+		}
+		if chunk.Op != Delete {
+			to = append(to, chunk.Text)
+		}
+		if chunk.Op == Equal {
+			equal += len(chunk.Text)
+		}
+	}
+	if got := strings.Join(from, ""); got != strings.Join(a, "") {
+		t.Errorf("expected %s, got %s", strings.Join(a, ""), got)
+	}
+	if got := strings.Join(to, ""); got != strings.Join(b, "") {
+		t.Errorf("expected %s, got %s", strings.Join(b, ""), got)
+	}
+	// The longest common subsequence of the two is 8 tokens long.
+	if equal != 8 {
+		t.Errorf("expected 8 equal tokens, got %d", equal)
+	}
+}
+
+func TestTokensMaxWork(t *testing.T) {
+	// The middles need MaxWork*4 comparisons, more than allowed, so they're
+	// replaced whole while the common ends are kept.
+	n := 1 << 12
+	a := append(append([]string{"start"}, repeat("a", n)...), "end")
+	b := append(append([]string{"start"}, repeat("b", MaxWork/n*4)...), "end")
+	want := []Chunk{
+		{Equal, "start"},
+		{Delete, strings.Repeat("a", n)},
+		{Insert, strings.Repeat("b", MaxWork/n*4)},
+		{Equal, "end"},
+	}
+	if got := Tokens(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected a whole replace of the middle, got %d chunks", len(got))
+	}
+
+	// Within the limit, common tokens in the middle are still found.
+	a = []string{"x", "a", "y"}
+	b = []string{"z", "a", "w"}
+	want = []Chunk{{Delete, "x"}, {Insert, "z"}, {Equal, "a"}, {Delete, "y"}, {Insert, "w"}}
+	if got := Tokens(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func repeat(token string, n int) []string {
+	tokens := make([]string, n)
+	for i := range tokens {
+		tokens[i] = token
+	}
+	return tokens
+}
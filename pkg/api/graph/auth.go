@@ -0,0 +1,47 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/mail"
+	"net/url"
+)
+
+func UserTokenOf(token *auth.SessionToken) *model.UserToken {
+	return &model.UserToken{
+		Token:            token.AccessToken,
+		ExpiresAt:        token.AccessExpiresAt,
+		RefreshToken:     token.RefreshToken,
+		RefreshExpiresAt: token.RefreshExpiresAt,
+	}
+}
+
+// SendEmailVerification mails a user a link to verify an address. Pass the
+// transaction that creates the user or changes their address.
+func (r *Resolver) SendEmailVerification(tx *database.DB, user database.User, email string) error {
+	token, err := auth.CreateEmailVerification(tx, user.ID, email)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/verify-email?token=%s", r.BaseURL, url.QueryEscape(token))
+	return mail.Enqueue(tx, mail.EmailVerification(email, user.Name, link))
+}
+
+// CheckEmailVerified enforces the verified email policy for a user.
+func (r *Resolver) CheckEmailVerified(ctx context.Context, userID int) error {
+	if !r.VerifiedEmailRequired {
+		return nil
+	}
+	ok, err := auth.EmailVerified(r.DB.WithContext(ctx), userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apierror.New(apierror.CodeForbidden, "email not verified")
+	}
+	return nil
+}
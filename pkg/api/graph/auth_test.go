@@ -0,0 +1,180 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/client"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"testing"
+)
+
+const refreshQuery = `mutation($token: String!) { refresh(refreshToken: $token) { token refreshToken } }`
+
+type refreshResponse struct {
+	Refresh struct{ Token, RefreshToken string }
+}
+
+func TestCreateUserConflict(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+	var resp map[string]interface{}
+	err := s.Post(`mutation { createUser(name: "alice", email: "other@example.com", password: "correct horse battery") { id } }`, &resp)
+	if e := expectCode(t, err, "CONFLICT"); e.Extensions.Field != "name" {
+		t.Errorf("expected conflict on name, got %q", e.Extensions.Field)
+	}
+}
+
+func TestCreateUserValidation(t *testing.T) {
+	s := newTestServer(t)
+	var resp map[string]interface{}
+	err := s.Post(`mutation { createUser(name: "alice", email: "not an email", password: "correct horse battery") { id } }`, &resp)
+	if e := expectCode(t, err, "VALIDATION"); e.Extensions.Field != "email" {
+		t.Errorf("expected invalid email, got %q", e.Extensions.Field)
+	}
+	err = s.Post(`mutation { createUser(name: "alice", email: "alice@example.com", password: "password") { id } }`, &resp)
+	if e := expectCode(t, err, "VALIDATION"); e.Extensions.Field != "password" {
+		t.Errorf("expected invalid password, got %q", e.Extensions.Field)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+	_, refresh := s.login("alice")
+
+	var resp refreshResponse
+	s.post(refreshQuery, &resp, client.Var("token", refresh))
+	next := resp.Refresh.RefreshToken
+	if next == "" || next == refresh {
+		t.Fatalf("expected a new refresh token, got %q", next)
+	}
+	// The new access token works.
+	var viewer map[string]interface{}
+	s.post(`mutation { createRule(summary: "rule") { id } }`, &viewer, bearer(resp.Refresh.Token))
+	s.post(refreshQuery, &resp, client.Var("token", next))
+}
+
+func TestRefreshInvalidDoesNotRevoke(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+	_, refresh := s.login("alice")
+
+	// Anyone can guess session IDs, so a wrong secret mustn't end the session.
+	var resp refreshResponse
+	err := s.Post(refreshQuery, &resp, client.Var("token", "1.bogus"))
+	if e := expectCode(t, err, "UNAUTHENTICATED"); e.Message != auth.ErrRefreshInvalid.Message {
+		t.Errorf("expected invalid refresh token, got %q", e.Message)
+	}
+	s.post(refreshQuery, &resp, client.Var("token", refresh))
+}
+
+func TestRefreshReuseRevokes(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+	_, refresh := s.login("alice")
+
+	var resp refreshResponse
+	s.post(refreshQuery, &resp, client.Var("token", refresh))
+	next := resp.Refresh.RefreshToken
+	err := s.Post(refreshQuery, &resp, client.Var("token", refresh))
+	if e := expectCode(t, err, "UNAUTHENTICATED"); e.Message != auth.ErrRefreshReused.Message {
+		t.Errorf("expected reused refresh token, got %q", e.Message)
+	}
+	// Reuse revokes the whole session, including the token rotated in.
+	err = s.Post(refreshQuery, &resp, client.Var("token", next))
+	expectCode(t, err, "UNAUTHENTICATED")
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+	token, refresh := s.login("alice")
+
+	var resp map[string]interface{}
+	s.post(`mutation { logout }`, &resp, bearer(token))
+	err := s.Post(`mutation { createRule(summary: "rule") { id } }`, &resp, bearer(token))
+	expectCode(t, err, "UNAUTHENTICATED")
+	err = s.Post(refreshQuery, &resp, client.Var("token", refresh))
+	expectCode(t, err, "UNAUTHENTICATED")
+}
+
+func TestLoginLockoutIsUniform(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+
+	login := `mutation($email: String!, $password: String!) { login(email: $email, password: $password) { token } }`
+	var resp map[string]interface{}
+	for _, email := range []string{"alice@example.com", "nobody@example.com"} {
+		for i := 0; i < auth.LockoutThreshold+2; i++ {
+			err := s.Post(login, &resp, client.Var("email", email), client.Var("password", "wrong password"))
+			if e := expectCode(t, err, "UNAUTHENTICATED"); e.Message != auth.ErrLoginInvalid.Message {
+				t.Fatalf("%s attempt %d: expected invalid login, got %q", email, i+1, e.Message)
+			}
+		}
+	}
+	// Locked out accounts fail like any other, even with the right password.
+	err := s.Post(login, &resp, client.Var("email", "alice@example.com"), client.Var("password", testPassword))
+	if e := expectCode(t, err, "UNAUTHENTICATED"); e.Message != auth.ErrLoginInvalid.Message {
+		t.Errorf("expected invalid login, got %q", e.Message)
+	}
+	var failures []database.LoginFailure
+	if err := s.DB.Order("email").Find(&failures).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 2 || failures[0].LockedUntil == nil || failures[1].LockedUntil == nil {
+		t.Errorf("expected both emails locked out, got %+v", failures)
+	}
+}
+
+func TestLoginClearsFailures(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+
+	var resp map[string]interface{}
+	err := s.Post(`mutation { login(email: "alice@example.com", password: "wrong password") { token } }`, &resp)
+	expectCode(t, err, "UNAUTHENTICATED")
+	s.login("alice")
+	var count int64
+	if err := s.DB.Model(&database.LoginFailure{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected failures to be cleared, got %d", count)
+	}
+}
+
+func TestLoginIgnoresEmailCase(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+
+	login := `mutation($email: String!, $password: String!) { login(email: $email, password: $password) { token } }`
+	var resp map[string]interface{}
+	err := s.Post(login, &resp, client.Var("email", "Alice@Example.com"), client.Var("password", "wrong password"))
+	expectCode(t, err, "UNAUTHENTICATED")
+	var failures []database.LoginFailure
+	if err := s.DB.Find(&failures).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 1 || failures[0].Email != "alice@example.com" {
+		t.Errorf("expected a failure of alice@example.com, got %+v", failures)
+	}
+	s.post(login, &resp, client.Var("email", "ALICE@example.com"), client.Var("password", testPassword))
+}
+
+func TestVerifyEmailConflict(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+	s.signup("bob")
+	var alice database.User
+	if err := s.DB.Where("name = ?", "alice").First(&alice).Error; err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.CreateEmailVerification(s.DB, alice.ID, "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp map[string]interface{}
+	err = s.Post(`mutation($token: String!) { verifyEmail(token: $token) }`, &resp, client.Var("token", token))
+	if e := expectCode(t, err, "CONFLICT"); e.Extensions.Field != "email" {
+		t.Errorf("expected conflict on email, got %q", e.Extensions.Field)
+	}
+}
@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/client"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"testing"
+	"time"
+)
+
+type decision struct {
+	Status  string
+	Decided *string
+	Decider *struct{ Name string }
+}
+
+// decide makes a decision on a rule, returning the rule as decided or the
+// error refusing it.
+func (s *testServer) decide(as client.Option, mutation, id string) (decision, error) {
+	s.t.Helper()
+	var resp map[string]decision
+	err := s.Post(`mutation($id: ID!) { `+mutation+`(id: $id) { status decided decider { name } } }`, &resp, as, client.Var("id", id))
+	return resp[mutation], err
+}
+
+func TestDecideRule(t *testing.T) {
+	s := newTestServer(t)
+	dictator := s.userWithRole("dictator", database.RoleDictator)
+	alice := s.user("alice")
+	s.Resolver.ProposalPeriod = time.Hour
+	id := s.createRule(alice, "rule")
+
+	// Only the dictator decides, and only once voting ends.
+	_, err := s.decide(alice, "ratifyRule", id)
+	expectCode(t, err, "FORBIDDEN")
+	_, err = s.decide(dictator, "ratifyRule", id)
+	expectCode(t, err, "CONFLICT")
+	if err := s.DB.Model(&database.Rule{}).Where("1 = 1").Update("voting_ends", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	rule, err := s.decide(dictator, "ratifyRule", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Status != "RATIFIED" || rule.Decided == nil || rule.Decider == nil || rule.Decider.Name != "dictator" {
+		t.Errorf("expected the rule ratified by the dictator, got %+v", rule)
+	}
+
+	// A ratified rule can only be repealed.
+	for _, mutation := range []string{"ratifyRule", "vetoRule"} {
+		_, err := s.decide(dictator, mutation, id)
+		expectCode(t, err, "CONFLICT")
+	}
+	if rule, err := s.decide(dictator, "repealRule", id); err != nil || rule.Status != "REPEALED" {
+		t.Errorf("expected the rule repealed, got %+v, %v", rule, err)
+	}
+	_, err = s.decide(dictator, "repealRule", id)
+	expectCode(t, err, "CONFLICT")
+
+	// A proposed rule may be vetoed while open to votes, after which it can't
+	// be ratified or repealed.
+	vetoed := s.createRule(alice, "vetoed")
+	_, err = s.decide(dictator, "repealRule", vetoed)
+	expectCode(t, err, "CONFLICT")
+	if rule, err := s.decide(dictator, "vetoRule", vetoed); err != nil || rule.Status != "VETOED" {
+		t.Errorf("expected the rule vetoed, got %+v, %v", rule, err)
+	}
+	for _, mutation := range []string{"ratifyRule", "repealRule", "vetoRule"} {
+		_, err := s.decide(dictator, mutation, vetoed)
+		expectCode(t, err, "CONFLICT")
+	}
+
+	_, err = s.decide(dictator, "vetoRule", model.GlobalID{Type: "Rule", ID: 999}.String())
+	expectCode(t, err, "NOT_FOUND")
+}
@@ -0,0 +1,90 @@
+package graph
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/generated"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/validate"
+)
+
+// NewConfig returns the schema config for a resolver with its directives.
+func NewConfig(r *Resolver) generated.Config {
+	return generated.Config{
+		Resolvers:  r,
+		Complexity: Complexity(),
+		Directives: generated.DirectiveRoot{
+			Authenticated: Authenticated,
+			HasRole:       HasRole,
+			Length:        Length,
+			Email:         Email,
+		},
+	}
+}
+
+// Authenticated requires a signed in user.
+func Authenticated(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if auth.ForContext(ctx) == nil {
+		return nil, apierror.ErrUnauthenticated
+	}
+	return next(ctx)
+}
+
+// HasRole requires a signed in user with at least the given role.
+func HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, role model.Role) (interface{}, error) {
+	userAuth := auth.ForContext(ctx)
+	if userAuth == nil {
+		return nil, apierror.ErrUnauthenticated
+	}
+	if !auth.HasRole(userAuth.Role, string(role)) {
+		return nil, apierror.ErrForbidden
+	}
+	return next(ctx)
+}
+
+// Length requires a string argument or input field to have at least min and
+// at most max characters.
+func Length(ctx context.Context, obj interface{}, next graphql.Resolver, min *int, max *int) (interface{}, error) {
+	return validateString(ctx, next, func(field, value string) error {
+		var lo, hi int
+		if min != nil {
+			lo = *min
+		}
+		if max != nil {
+			hi = *max
+		}
+		return validate.Length(field, value, lo, hi)
+	})
+}
+
+// Email requires a string argument or input field to be an email address.
+func Email(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	return validateString(ctx, next, validate.Email)
+}
+
+// validateString validates the value of the argument or input field in ctx,
+// unless it's null.
+func validateString(ctx context.Context, next graphql.Resolver, check func(field, value string) error) (interface{}, error) {
+	v, err := next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var field string
+	if pc := graphql.GetPathContext(ctx); pc != nil && pc.Field != nil {
+		field = *pc.Field
+	}
+	switch value := v.(type) {
+	case string:
+		err = check(field, value)
+	case *string:
+		if value != nil {
+			err = check(field, *value)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/client"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"testing"
+)
+
+func TestAuthenticated(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	var resp map[string]interface{}
+	for _, query := range []string{
+		`mutation { createRule(summary: "rule") { id } }`,
+		`mutation { updateUser(name: "alice") { id } }`,
+		`mutation { logout }`,
+	} {
+		err := s.Post(query, &resp)
+		expectCode(t, err, "UNAUTHENTICATED")
+		err = s.Post(query, &resp, bearer("not a token"))
+		expectCode(t, err, "UNAUTHENTICATED")
+		if err := s.Post(query, &resp, alice); err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+}
+
+// setRole sets a user's role, returning the role they were given or the error
+// refusing it.
+func (s *testServer) setRole(as client.Option, userID, role string) (string, error) {
+	s.t.Helper()
+	var resp struct {
+		SetUserRole struct{ Role string }
+	}
+	err := s.Post(`mutation($id: ID!, $role: Role!) { setUserRole(userId: $id, role: $role) { role } }`,
+		&resp, as, client.Var("id", userID), client.Var("role", role))
+	return resp.SetUserRole.Role, err
+}
+
+func TestHasRole(t *testing.T) {
+	s := newTestServer(t)
+	dictator := s.userWithRole("dictator", database.RoleDictator)
+	admin := s.userWithRole("admin", database.RoleAdmin)
+	alice := s.user("alice")
+	bobID := s.signup("bob")
+	var row database.User
+	if err := s.DB.Where("name = ?", "dictator").First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	dictatorID := model.GlobalID{Type: "User", ID: row.ID}.String()
+
+	_, err := s.setRole(alice, bobID, database.RoleModerator)
+	expectCode(t, err, "FORBIDDEN")
+	if role, err := s.setRole(admin, bobID, database.RoleModerator); err != nil || role != database.RoleModerator {
+		t.Errorf("expected bob made a moderator, got %s, %v", role, err)
+	}
+	// Nobody grants a role above their own or demotes someone above them.
+	_, err = s.setRole(admin, bobID, database.RoleDictator)
+	expectCode(t, err, "FORBIDDEN")
+	_, err = s.setRole(admin, dictatorID, database.RoleMember)
+	expectCode(t, err, "FORBIDDEN")
+	// Higher roles may do whatever lower ones can.
+	if role, err := s.setRole(dictator, bobID, database.RoleAdmin); err != nil || role != database.RoleAdmin {
+		t.Errorf("expected bob made an admin, got %s, %v", role, err)
+	}
+	bobToken, _ := s.login("bob")
+	if _, err := s.setRole(bearer(bobToken), bobID, database.RoleMember); err != nil {
+		t.Errorf("expected the new admin to set roles, got %v", err)
+	}
+	// Demotions apply to tokens already issued.
+	_, err = s.setRole(bearer(bobToken), bobID, database.RoleModerator)
+	expectCode(t, err, "FORBIDDEN")
+
+	id := s.createRule(alice, "rule")
+	var resp map[string]interface{}
+	err = s.Post(`mutation($id: ID!) { vetoRule(id: $id) { id } }`, &resp, admin, client.Var("id", id))
+	expectCode(t, err, "FORBIDDEN")
+	s.post(`mutation($id: ID!) { vetoRule(id: $id) { id } }`, &resp, dictator, client.Var("id", id))
+}
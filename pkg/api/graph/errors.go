@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"log"
+	"math"
+	"strings"
+)
+
+// ErrorPresenter adds the code of errors to their extensions. Errors that
+// aren't an apierror.Error or one of gqlgen's own, such as database errors,
+// are logged and masked as internal errors so they don't leak details of the
+// server. Errors of requests that ran out of time are reported as timeouts.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	var apiErr *apierror.Error
+	var gqlErr *gqlerror.Error
+	switch {
+	case errors.As(err, &apiErr):
+		gqlErr = graphql.DefaultErrorPresenter(ctx, err)
+		gqlErr.Message = apiErr.Message
+		setErrorCode(gqlErr, apiErr.Code)
+		if apiErr.Field != "" {
+			gqlErr.Extensions["field"] = apiErr.Field
+		}
+		if apiErr.RetryAfter > 0 {
+			gqlErr.Extensions["retryAfter"] = int(math.Ceil(apiErr.RetryAfter.Seconds()))
+		}
+	case errors.As(err, &gqlErr) && gqlErr.Unwrap() == nil:
+		// gqlgen's own errors are about invalid requests.
+		setErrorCode(gqlErr, apierror.CodeValidation)
+	case errors.Is(err, context.DeadlineExceeded) || ctx.Err() == context.DeadlineExceeded:
+		gqlErr = &gqlerror.Error{Path: graphql.GetPath(ctx), Message: "request timed out"}
+		setErrorCode(gqlErr, apierror.CodeTimeout)
+	default:
+		if gqlErr != nil {
+			err = gqlErr.Unwrap()
+		}
+		path := graphql.GetPath(ctx)
+		log.Printf("internal error at %s: %v", path, err)
+		gqlErr = &gqlerror.Error{Path: path, Message: "internal server error"}
+		setErrorCode(gqlErr, apierror.CodeInternal)
+	}
+	return gqlErr
+}
+
+func setErrorCode(err *gqlerror.Error, code apierror.Code) {
+	if err.Extensions == nil {
+		err.Extensions = map[string]interface{}{}
+	}
+	err.Extensions["code"] = code
+}
+
+// ConflictOf returns a conflict error about the columns of the unique
+// constraint that err violates, or nil if it's some other error.
+func ConflictOf(err error) error {
+	columns, ok := database.UniqueViolation(err)
+	if !ok {
+		return nil
+	}
+	if len(columns) == 0 {
+		return apierror.Conflict("", "already exists")
+	}
+	field := strings.Join(columns, ", ")
+	return apierror.Conflict(field, "%s is already taken", field)
+}
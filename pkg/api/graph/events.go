@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// Publish notifies subscribers of an event. Mutations have already succeeded
+// by the time they publish, so failures are logged rather than returned.
+func (r *Resolver) Publish(ctx context.Context, topic string, event any) {
+	if r.PubSub == nil {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("publish %s encode error: %v", topic, err)
+		return
+	}
+	if err := r.PubSub.Publish(ctx, topic, payload); err != nil {
+		log.Printf("publish %s error: %v", topic, err)
+	}
+}
+
+// Subscribe maps the events of a topic to subscription results. Events that
+// map to ok=false are skipped, and errors are logged and skip the event too.
+func Subscribe[E, T any](ctx context.Context, r *Resolver, topic string, f func(E) (t T, ok bool, err error)) <-chan T {
+	out := make(chan T, 1)
+	events := r.PubSub.Subscribe(ctx, topic)
+	go func() {
+		defer close(out)
+		for payload := range events {
+			var event E
+			if err := json.Unmarshal(payload, &event); err != nil {
+				log.Printf("subscribe %s decode error: %v", topic, err)
+				continue
+			}
+			t, ok, err := f(event)
+			if err != nil {
+				log.Printf("subscribe %s error: %v", topic, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			select {
+			case out <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
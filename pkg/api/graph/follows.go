@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// SetFollowing follows or unfollows a user on behalf of the viewer, which does
+// nothing if they already do or don't.
+func (r *Resolver) SetFollowing(ctx context.Context, userID model.GlobalID, following bool) (*model.User, error) {
+	userAuth := auth.ForContext(ctx)
+	id, err := userID.Of("User")
+	if err != nil {
+		return nil, err
+	}
+	if id == userAuth.UserID {
+		return nil, apierror.Invalid("users can't follow themselves")
+	}
+	if following {
+		if err := r.CheckEmailVerified(ctx, userAuth.UserID); err != nil {
+			return nil, err
+		}
+	}
+	var row database.User
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&database.User{ID: id}).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apierror.NotFound("user %s not found", userID)
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		follow := database.Follow{FollowerID: userAuth.UserID, FolloweeID: id}
+		if following {
+			follow.Created = time.Now().Round(0) // Drop monotonic clock reading.
+			err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow).Error
+		} else {
+			err = tx.Where(&follow).Delete(&database.Follow{}).Error
+		}
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	user := UserOf(row)
+	return &user, nil
+}
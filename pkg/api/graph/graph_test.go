@@ -0,0 +1,176 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/generated"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/loader"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/pubsub"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/ratelimit"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/validate"
+	"gorm.io/gorm/logger"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const testPassword = "correct horse battery"
+
+// testSessionCheckInterval is how often websockets check their session, much
+// more often than in production so that tests needn't wait.
+const testSessionCheckInterval = time.Millisecond * 50
+
+// testServer serves the schema over an in-memory SQLite database, set up the
+// way server.go sets up the real one.
+type testServer struct {
+	*client.Client
+	t        *testing.T
+	DB       *database.DB
+	Resolver *Resolver
+	Handler  http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	db, err := database.Open("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if conn, err := db.DB(); err == nil {
+			conn.Close()
+		}
+	})
+	r := &Resolver{
+		DB:             db,
+		PubSub:         pubsub.NewHub(),
+		BaseURL:        "http://localhost",
+		PasswordPolicy: validate.DefaultPasswordPolicy(),
+	}
+	srv := handler.New(generated.NewExecutableSchema(NewConfig(r)))
+	srv.AddTransport(transport.Websocket{
+		InitFunc: func(ctx context.Context, initPayload transport.InitPayload) (context.Context, error) {
+			if userAuth := auth.Authenticate(ctx, db, initPayload.Authorization()); userAuth != nil {
+				ctx = auth.WithUserAuth(ctx, userAuth)
+				ctx = auth.WatchSession(ctx, db, userAuth, testSessionCheckInterval)
+			}
+			return ctx, nil
+		},
+	})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(&QueryLimit{MaxDepth: DefaultMaxDepth, MaxComplexity: DefaultMaxComplexity})
+	srv.AroundOperations(loader.Operations(db))
+	srv.AroundResponses(loader.Responses(db))
+	srv.AroundResponses(Timeout(DefaultRequestTimeout))
+	srv.SetErrorPresenter(ErrorPresenter)
+	h := ratelimit.Handle(false)(auth.Handle(db)(srv))
+	return &testServer{Client: client.New(h), t: t, DB: db, Resolver: r, Handler: h}
+}
+
+// post runs a query that must succeed.
+func (s *testServer) post(query string, resp interface{}, options ...client.Option) {
+	s.t.Helper()
+	if err := s.Post(query, resp, options...); err != nil {
+		s.t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// signup creates a user with an email of name@example.com and returns their
+// ID.
+func (s *testServer) signup(name string) string {
+	s.t.Helper()
+	var resp struct{ CreateUser struct{ ID string } }
+	s.post(`mutation($name: String!, $email: String!, $password: String!) {
+		createUser(name: $name, email: $email, password: $password) { id }
+	}`, &resp, client.Var("name", name), client.Var("email", name+"@example.com"), client.Var("password", testPassword))
+	return resp.CreateUser.ID
+}
+
+// login logs in as a user made by signup and returns their tokens.
+func (s *testServer) login(name string) (string, string) {
+	s.t.Helper()
+	var resp struct {
+		Login struct{ Token, RefreshToken string }
+	}
+	s.post(`mutation($email: String!, $password: String!) {
+		login(email: $email, password: $password) { token refreshToken }
+	}`, &resp, client.Var("email", name+"@example.com"), client.Var("password", testPassword))
+	return resp.Login.Token, resp.Login.RefreshToken
+}
+
+// user signs up a user and logs them in, returning an option that makes
+// requests as them.
+func (s *testServer) user(name string) client.Option {
+	s.t.Helper()
+	s.signup(name)
+	token, _ := s.login(name)
+	return bearer(token)
+}
+
+// userWithRole signs up a user with a role and logs them in.
+func (s *testServer) userWithRole(name, role string) client.Option {
+	s.t.Helper()
+	s.signup(name)
+	if err := s.DB.Model(&database.User{}).Where("name = ?", name).Update("role", role).Error; err != nil {
+		s.t.Fatal(err)
+	}
+	token, _ := s.login(name)
+	return bearer(token)
+}
+
+// createRule creates a rule as a user and returns its ID.
+func (s *testServer) createRule(as client.Option, summary string) string {
+	s.t.Helper()
+	var resp struct{ CreateRule struct{ ID string } }
+	s.post(`mutation($summary: String!) { createRule(summary: $summary) { id } }`, &resp, as, client.Var("summary", summary))
+	return resp.CreateRule.ID
+}
+
+func bearer(token string) client.Option {
+	return client.AddHeader("Authorization", "Bearer "+token)
+}
+
+// testError is an error as presented to clients.
+type testError struct {
+	Message    string
+	Extensions struct {
+		Code  string
+		Field string
+	}
+}
+
+// errorOf returns the first error of a failed query.
+func errorOf(t *testing.T, err error) testError {
+	t.Helper()
+	var raw client.RawJsonError
+	if !errors.As(err, &raw) {
+		t.Fatalf("expected an error response, got %v", err)
+	}
+	var errs []testError
+	if err := json.Unmarshal(raw.RawMessage, &errs); err != nil || len(errs) == 0 {
+		t.Fatalf("unexpected errors %s", raw.RawMessage)
+	}
+	return errs[0]
+}
+
+// expectCode checks that a query failed with an error code.
+func expectCode(t *testing.T, err error, code string) testError {
+	t.Helper()
+	e := errorOf(t, err)
+	if e.Extensions.Code != code {
+		t.Fatalf("expected %s error, got %s: %s", code, e.Extensions.Code, e.Message)
+	}
+	return e
+}
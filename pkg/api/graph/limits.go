@@ -0,0 +1,194 @@
+package graph
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/generated"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxDepth       = 12
+	DefaultMaxComplexity  = 5000
+	DefaultRequestTimeout = time.Second * 10
+	// DiffComplexity is the complexity of diffing two revisions, which is far
+	// more work than reading a field.
+	DiffComplexity = 500
+)
+
+// QueryLimit refuses operations that nest fields deeper than MaxDepth or whose
+// complexity is more than MaxComplexity, unless they're 0. Introspection isn't
+// counted towards the depth of operations.
+type QueryLimit struct {
+	MaxDepth      int
+	MaxComplexity int
+	schema        graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &QueryLimit{}
+
+func (l *QueryLimit) ExtensionName() string {
+	return "QueryLimit"
+}
+
+func (l *QueryLimit) Validate(schema graphql.ExecutableSchema) error {
+	l.schema = schema
+	return nil
+}
+
+func (l *QueryLimit) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if l.MaxDepth > 0 {
+		if depth := selectionDepth(rc.Operation.SelectionSet); depth > l.MaxDepth {
+			return queryLimitError("operation has depth %d, which exceeds the limit of %d", depth, l.MaxDepth)
+		}
+	}
+	if l.MaxComplexity > 0 {
+		if n := complexity.Calculate(l.schema, rc.Operation, rc.Variables); n > l.MaxComplexity {
+			return queryLimitError("operation has complexity %d, which exceeds the limit of %d", n, l.MaxComplexity)
+		}
+	}
+	return nil
+}
+
+func queryLimitError(format string, args ...interface{}) *gqlerror.Error {
+	return gqlerror.WrapPath(nil, apierror.New(apierror.CodeQueryTooComplex, format, args...))
+}
+
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, sel := range set {
+		var d int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name, "__") {
+				continue
+			}
+			d = 1 + selectionDepth(sel.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			d = selectionDepth(sel.Definition.SelectionSet)
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// pageComplexity is the complexity of a connection, which reads a page of
+// rows each as complex as childComplexity.
+func pageComplexity(childComplexity int, first, last *int) int {
+	size := DefaultPageSize
+	switch {
+	case first != nil:
+		size = *first
+	case last != nil:
+		size = *last
+	}
+	return listComplexity(childComplexity, size)
+}
+
+// listComplexity is the complexity of a list of size items each as complex as
+// childComplexity. Sizes are clamped to MaxPageSize, and the product saturates
+// so that huge sizes can't overflow into small or negative complexities.
+func listComplexity(childComplexity, size int) int {
+	if size < 1 {
+		size = 1
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+	if childComplexity < 1 {
+		childComplexity = 1
+	}
+	if childComplexity > (math.MaxInt-1)/size {
+		return math.MaxInt
+	}
+	return 1 + size*childComplexity
+}
+
+// Complexity returns the complexity of fields that aren't just the sum of
+// their selections.
+func Complexity() generated.ComplexityRoot {
+	var c generated.ComplexityRoot
+	c.Comment.Replies = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.Query.Feed = func(childComplexity int, first *int, after *string, last *int, before *string) int {
+		return pageComplexity(childComplexity, first, last)
+	}
+	c.Query.Notifications = func(childComplexity int, unreadOnly *bool, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.Query.Rules = func(childComplexity int, first *int, after *string, last *int, before *string, filter *model.RuleFilter, orderBy *model.RuleOrder) int {
+		return pageComplexity(childComplexity, first, last)
+	}
+	c.Query.SearchRules = func(childComplexity int, query string, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.Query.Tags = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.Query.Users = func(childComplexity int, first *int, after *string, last *int, before *string, name *string) int {
+		return pageComplexity(childComplexity, first, last)
+	}
+	c.Query.Nodes = func(childComplexity int, ids []*model.GlobalID) int {
+		return listComplexity(childComplexity, len(ids))
+	}
+	c.Rule.Diff = func(childComplexity int, from int, to int) int {
+		if childComplexity > math.MaxInt-DiffComplexity {
+			return math.MaxInt
+		}
+		return DiffComplexity + childComplexity
+	}
+	c.Rule.Revisions = func(childComplexity int) int {
+		return listComplexity(childComplexity, DefaultPageSize)
+	}
+	c.Rule.Comments = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.Rule.Likes = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.User.Followers = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.User.Following = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.User.Likes = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	c.User.Rules = func(childComplexity int, first *int, after *string, last *int, before *string) int {
+		return pageComplexity(childComplexity, first, last)
+	}
+	c.Webhook.Deliveries = func(childComplexity int, first *int, after *string) int {
+		return pageComplexity(childComplexity, first, nil)
+	}
+	return c
+}
+
+// Timeout cancels the resolution of queries and mutations that take longer
+// than d, unless it's 0. Subscriptions wait for events within responses, so
+// they aren't limited.
+func Timeout(d time.Duration) graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		if op := graphql.GetOperationContext(ctx); d == 0 || op.Operation != nil && op.Operation.Operation == ast.Subscription {
+			return next(ctx)
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return next(ctx)
+	}
+}
@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/client"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/ratelimit"
+	"strings"
+	"testing"
+)
+
+func TestQueryDepthLimit(t *testing.T) {
+	s := newTestServer(t)
+	// Each level of user { rules { edges { node ... is four deep.
+	query := "{ rules(first: 1) { edges { node { id " +
+		strings.Repeat("user { rules(first: 1) { edges { node { id ", 3) +
+		strings.Repeat("} } } } ", 3) + "} } } }"
+	var resp map[string]interface{}
+	err := s.Post(query, &resp)
+	if e := expectCode(t, err, "QUERY_TOO_COMPLEX"); !strings.Contains(e.Message, "depth") {
+		t.Errorf("expected depth limit, got %q", e.Message)
+	}
+
+	// Introspection is exempt.
+	s.post(`{ __schema { types { name fields { name type { name ofType { name ofType { name ofType { name } } } } } } } }`, &resp)
+}
+
+func TestQueryComplexityLimit(t *testing.T) {
+	s := newTestServer(t)
+	var resp map[string]interface{}
+	s.post(`{ rules(first: 10) { edges { node { id likes(first: 10) { edges { node { id } } } } } } }`, &resp)
+	err := s.Post(`{ rules(first: 100) { edges { node { id likes(first: 100) { edges { node { id } } } } } } }`, &resp)
+	expectCode(t, err, "QUERY_TOO_COMPLEX")
+}
+
+func TestQueryComplexityOverflow(t *testing.T) {
+	s := newTestServer(t)
+	var resp map[string]interface{}
+	// A huge page size mustn't overflow into a small complexity that lets
+	// siblings through.
+	err := s.Post(`{
+		a: users(first: 9223372036854775807) { edges { node { id followers { edges { node { id } } } } } }
+		b: rules(first: 10) { edges { node { id likes(first: 10) { edges { node { id } } } } } }
+	}`, &resp)
+	expectCode(t, err, "QUERY_TOO_COMPLEX")
+}
+
+func TestQueryComplexityUnpagedLists(t *testing.T) {
+	s := newTestServer(t)
+	var resp map[string]interface{}
+	ids := make([]string, MaxPageSize)
+	for i := range ids {
+		ids[i] = "UnVsZTox"
+	}
+	err := s.Post(`query($ids: [ID!]!) { nodes(ids: $ids) { ... on Rule { likes(first: 100) { edges { node { id } } } } } }`,
+		&resp, client.Var("ids", ids))
+	expectCode(t, err, "QUERY_TOO_COMPLEX")
+
+	diffs := make([]string, DefaultMaxComplexity/DiffComplexity+1)
+	for i := range diffs {
+		diffs[i] = "d" + string(rune('a'+i)) + ": diff(from: 1, to: 2) { summary { op } }"
+	}
+	err = s.Post(`{ rules(first: 1) { edges { node { `+strings.Join(diffs, " ")+` } } } }`, &resp)
+	expectCode(t, err, "QUERY_TOO_COMPLEX")
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	s := newTestServer(t)
+	s.Resolver.RateLimits = ratelimit.NewMemoryStore()
+	var resp map[string]interface{}
+	query := `mutation { login(email: "nobody@example.com", password: "wrong password") { token } }`
+	var err error
+	for i := 0; i <= LoginAccountLimit.Burst; i++ {
+		err = s.Post(query, &resp)
+	}
+	expectCode(t, err, "RATE_LIMITED")
+}
@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/99designs/gqlgen/client"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm/logger"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queryCounter is a silent logger that counts the statements run.
+type queryCounter struct {
+	logger.Interface
+	mu sync.Mutex
+	n  int
+}
+
+func (c *queryCounter) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+}
+
+// count returns how many statements f runs.
+func (c *queryCounter) count(f func()) int {
+	c.mu.Lock()
+	c.n = 0
+	c.mu.Unlock()
+	f()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+func TestRulesBatchLoading(t *testing.T) {
+	s := newTestServer(t)
+	counter := &queryCounter{Interface: s.DB.Logger}
+	s.DB.Logger = counter
+
+	var users []client.Option
+	var ids []string
+	query := `{ rules { edges { node { id user { name } likes { edges { node { name } } } } } } }`
+	var queries []int
+	for i := 0; i < 4; i++ {
+		user := s.user(fmt.Sprintf("user%d", i))
+		users = append(users, user)
+		ids = append(ids, s.createRule(user, "rule"))
+		for _, liker := range users {
+			var resp map[string]interface{}
+			s.post(`mutation($ids: [ID!]) { like(add: $ids) { added } }`, &resp, liker, client.Var("ids", ids))
+		}
+		var resp map[string]interface{}
+		queries = append(queries, counter.count(func() { s.post(query, &resp) }))
+	}
+	// The authors and likes of every rule on the page are each read at once,
+	// however many rules there are.
+	for i, n := range queries[1:] {
+		if n != queries[0] {
+			t.Errorf("expected %d statements for %d rules, as for 1, got %d", queries[0], i+2, n)
+		}
+	}
+}
+
+// wsConn runs operations one after another over one websocket connection.
+type wsConn struct {
+	t    *testing.T
+	conn *websocket.Conn
+	next int
+}
+
+func (s *testServer) dialWebsocket(token string) *wsConn {
+	s.t.Helper()
+	srv := httptest.NewServer(s.Handler)
+	s.t.Cleanup(srv.Close)
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { conn.Close() })
+	ws := &wsConn{t: s.t, conn: conn}
+	ws.send(map[string]interface{}{"type": "connection_init", "payload": map[string]string{"Authorization": "Bearer " + token}})
+	if msg := ws.read(); msg.Type != "connection_ack" {
+		s.t.Fatalf("expected connection_ack, got %+v", msg)
+	}
+	return ws
+}
+
+type wsMessage struct {
+	ID      string
+	Type    string
+	Payload json.RawMessage
+}
+
+func (ws *wsConn) send(msg interface{}) {
+	ws.t.Helper()
+	if err := ws.conn.WriteJSON(msg); err != nil {
+		ws.t.Fatal(err)
+	}
+}
+
+func (ws *wsConn) read() wsMessage {
+	ws.t.Helper()
+	for {
+		var msg wsMessage
+		if err := ws.conn.ReadJSON(&msg); err != nil {
+			ws.t.Fatal(err)
+		}
+		if msg.Type != "ka" {
+			return msg
+		}
+	}
+}
+
+// post runs an operation that must succeed and waits for it to complete.
+func (ws *wsConn) post(query string, resp interface{}, variables map[string]interface{}) {
+	ws.t.Helper()
+	ws.next++
+	id := strconv.Itoa(ws.next)
+	ws.send(map[string]interface{}{"id": id, "type": "start", "payload": map[string]interface{}{"query": query, "variables": variables}})
+	msg := ws.read()
+	var payload struct {
+		Data   json.RawMessage
+		Errors json.RawMessage
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil || msg.Type != "data" || len(payload.Errors) != 0 {
+		ws.t.Fatalf("%s: unexpected %s %s", query, msg.Type, msg.Payload)
+	}
+	if err := json.Unmarshal(payload.Data, resp); err != nil {
+		ws.t.Fatal(err)
+	}
+	if msg := ws.read(); msg.Type != "complete" {
+		ws.t.Fatalf("%s: expected complete, got %+v", query, msg)
+	}
+}
+
+func TestLoadersPerOperation(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+	token, _ := s.login("alice")
+	id := s.createRule(bearer(token), "before")
+
+	// Every operation over a websocket sees the writes of those before it,
+	// rather than what the connection's first operation loaded.
+	ws := s.dialWebsocket(token)
+	query := `query($id: ID!) { node(id: $id) { ... on Rule { summary } } }`
+	var rule struct {
+		Node struct{ Summary string }
+	}
+	ws.post(query, &rule, map[string]interface{}{"id": id})
+	var resp map[string]interface{}
+	ws.post(`mutation($id: ID!) { updateRule(id: $id, summary: "after") { id } }`, &resp, map[string]interface{}{"id": id})
+	ws.post(query, &rule, map[string]interface{}{"id": id})
+	if rule.Node.Summary != "after" {
+		t.Errorf("expected the updated summary, got %q", rule.Node.Summary)
+	}
+}
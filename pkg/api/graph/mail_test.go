@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/client"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/mail"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// mailbox is a sender that keeps the messages it's given.
+type mailbox struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *mailbox) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+var tokenLink = regexp.MustCompile(`token=(\S+)`)
+
+// deliver sends the outbox to a mailbox and returns the token linked in its
+// last message.
+func (s *testServer) deliver(box *mailbox) string {
+	s.t.Helper()
+	d := mail.Dispatcher{DB: s.DB, Sender: box}
+	if _, err := d.Dispatch(context.Background()); err != nil {
+		s.t.Fatal(err)
+	}
+	if len(box.messages) == 0 {
+		s.t.Fatal("expected mail to be sent")
+	}
+	m := tokenLink.FindStringSubmatch(box.messages[len(box.messages)-1].Body)
+	if m == nil {
+		s.t.Fatalf("expected a token link, got %q", box.messages[len(box.messages)-1].Body)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token
+}
+
+func TestResetPassword(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice")
+	token, _ := s.login("alice")
+	var resp map[string]interface{}
+	login := `mutation($password: String!) { login(email: "alice@example.com", password: $password) { token } }`
+	for i := 0; i < auth.LockoutThreshold; i++ {
+		err := s.Post(login, &resp, client.Var("password", "wrong password"))
+		expectCode(t, err, "UNAUTHENTICATED")
+	}
+
+	// Unknown emails are accepted too, so as not to reveal accounts.
+	s.post(`mutation { requestPasswordReset(email: "nobody@example.com") }`, &resp)
+	s.post(`mutation { requestPasswordReset(email: "alice@example.com") }`, &resp)
+	box := &mailbox{}
+	reset := s.deliver(box)
+	for _, msg := range box.messages {
+		if msg.To != "alice@example.com" {
+			t.Errorf("expected mail only to alice, got mail to %s", msg.To)
+		}
+	}
+
+	query := `mutation($token: String!, $password: String!) { resetPassword(token: $token, newPassword: $password) }`
+	err := s.Post(query, &resp, client.Var("token", reset), client.Var("password", "password"))
+	expectCode(t, err, "VALIDATION")
+	s.post(query, &resp, client.Var("token", reset), client.Var("password", "a new horse battery"))
+	err = s.Post(query, &resp, client.Var("token", reset), client.Var("password", "a new horse battery"))
+	expectCode(t, err, "VALIDATION")
+
+	// A new password ends the lockout and every session.
+	s.post(login, &resp, client.Var("password", "a new horse battery"))
+	err = s.Post(login, &resp, client.Var("password", testPassword))
+	expectCode(t, err, "UNAUTHENTICATED")
+	err = s.Post(`mutation { createRule(summary: "rule") { id } }`, &resp, bearer(token))
+	expectCode(t, err, "UNAUTHENTICATED")
+}
+
+func TestVerifyEmail(t *testing.T) {
+	s := newTestServer(t)
+	s.Resolver.VerifiedEmailRequired = true
+	alice := s.user("alice")
+	var resp map[string]interface{}
+	err := s.Post(`mutation { createRule(summary: "rule") { id } }`, &resp, alice)
+	expectCode(t, err, "FORBIDDEN")
+
+	token := s.deliver(&mailbox{})
+	verify := `mutation($token: String!) { verifyEmail(token: $token) }`
+	s.post(verify, &resp, client.Var("token", token))
+	s.createRule(alice, "rule")
+	err = s.Post(verify, &resp, client.Var("token", token))
+	expectCode(t, err, "VALIDATION")
+
+	// A new address replaces the old one once it's verified, and only the
+	// latest token verifies one.
+	s.post(`mutation { updateUser(email: "alice@example.net") { id } }`, &resp, alice)
+	stale := s.deliver(&mailbox{})
+	s.post(`mutation { updateUser(email: "alice@example.org") { id } }`, &resp, alice)
+	box := &mailbox{}
+	token = s.deliver(box)
+	err = s.Post(verify, &resp, client.Var("token", stale))
+	expectCode(t, err, "VALIDATION")
+	if to := box.messages[len(box.messages)-1].To; to != "alice@example.org" {
+		t.Fatalf("expected mail to the new address, got mail to %s", to)
+	}
+	login := `mutation($email: String!) { login(email: $email, password: "` + testPassword + `") { token } }`
+	s.post(login, &resp, client.Var("email", "alice@example.com"))
+	s.post(verify, &resp, client.Var("token", token))
+	s.post(login, &resp, client.Var("email", "alice@example.org"))
+}
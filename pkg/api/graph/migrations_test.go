@@ -0,0 +1,31 @@
+package graph
+
+import (
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"testing"
+)
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	s := newTestServer(t)
+	s.user("alice")
+	migrations, err := database.Migrations(s.DB.Dialector.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reverted, err := database.MigrateDown(s.DB, len(migrations))
+	if err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if len(reverted) != len(migrations) {
+		t.Errorf("expected %d migrations reverted, got %d", len(migrations), len(reverted))
+	}
+	applied, err := database.MigrateUp(s.DB)
+	if err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("expected %d migrations applied, got %d", len(migrations), len(applied))
+	}
+	// The schema works again from scratch.
+	s.user("bob")
+}
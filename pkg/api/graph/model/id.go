@@ -0,0 +1,62 @@
+package model
+
+import (
+	"encoding/base64"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// GlobalID identifies any node in the graph by its type name and row ID. It's
+// presented to clients as an opaque string so that they can refetch any node
+// by ID alone and can't mistake one type's ID for another's.
+type GlobalID struct {
+	Type string
+	ID   int
+}
+
+func (g GlobalID) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(g.Type + ":" + strconv.Itoa(g.ID)))
+}
+
+func ParseGlobalID(s string) (GlobalID, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return GlobalID{}, apierror.Invalid("invalid ID %q", s)
+	}
+	typ, id, ok := strings.Cut(string(b), ":")
+	if !ok || typ == "" {
+		return GlobalID{}, apierror.Invalid("invalid ID %q", s)
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil || n <= 0 {
+		return GlobalID{}, apierror.Invalid("invalid ID %q", s)
+	}
+	return GlobalID{Type: typ, ID: n}, nil
+}
+
+// Of returns the row ID if g identifies a node of type typ.
+func (g GlobalID) Of(typ string) (int, error) {
+	if g.Type != typ {
+		return 0, apierror.Invalid("ID %s is not a %s", g, typ)
+	}
+	return g.ID, nil
+}
+
+func (g GlobalID) MarshalGQL(w io.Writer) {
+	io.WriteString(w, strconv.Quote(g.String()))
+}
+
+func (g *GlobalID) UnmarshalGQL(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return apierror.Invalid("ID must be a string")
+	}
+	id, err := ParseGlobalID(s)
+	if err != nil {
+		return err
+	}
+	*g = id
+	return nil
+}
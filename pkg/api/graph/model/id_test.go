@@ -0,0 +1,56 @@
+package model
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestGlobalIDRoundTrip(t *testing.T) {
+	for _, id := range []GlobalID{{"User", 1}, {"Rule", 42}, {"Rule", 1 << 40}} {
+		got, err := ParseGlobalID(id.String())
+		if err != nil {
+			t.Errorf("%+v: %v", id, err)
+		} else if got != id {
+			t.Errorf("expected %+v, got %+v", id, got)
+		}
+	}
+}
+
+func TestGlobalIDOf(t *testing.T) {
+	id := GlobalID{"Rule", 7}
+	if n, err := id.Of("Rule"); err != nil || n != 7 {
+		t.Errorf("expected 7, got %d, %v", n, err)
+	}
+	if _, err := id.Of("User"); err == nil {
+		t.Error("expected a Rule ID to be refused as a User ID")
+	}
+}
+
+func TestParseGlobalIDMalformed(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for _, s := range []string{
+		"",
+		"1",
+		"not base64!",
+		encode("Rule"),
+		encode(":1"),
+		encode("Rule:"),
+		encode("Rule:x"),
+		encode("Rule:0"),
+		encode("Rule:-1"),
+	} {
+		if id, err := ParseGlobalID(s); err == nil {
+			t.Errorf("%q: expected an error, got %+v", s, id)
+		}
+	}
+}
+
+func TestGlobalIDUnmarshalGQL(t *testing.T) {
+	var id GlobalID
+	if err := id.UnmarshalGQL(GlobalID{"User", 3}.String()); err != nil || id != (GlobalID{"User", 3}) {
+		t.Errorf("expected User 3, got %+v, %v", id, err)
+	}
+	if err := id.UnmarshalGQL(3); err == nil {
+		t.Error("expected a non-string ID to be refused")
+	}
+}
@@ -1 +1,97 @@
 package model
+
+type User struct {
+	ID    int             `json:"-"`
+	Name  string          `json:"name"`
+	Role  Role            `json:"role"`
+	Rules *RuleConnection `json:"rules"`
+	Likes *RuleConnection `json:"likes"`
+}
+
+func (User) IsNode() {}
+
+type Rule struct {
+	ID         int             `json:"-"`
+	UserID     int             `json:"-"`
+	User       *User           `json:"user"`
+	Created    string          `json:"created"`
+	Updated    *string         `json:"updated"`
+	Summary    string          `json:"summary"`
+	Detail     *string         `json:"detail"`
+	Status     RuleStatus      `json:"status"`
+	VotingEnds *string         `json:"votingEnds"`
+	Decided    *string         `json:"decided"`
+	DeciderID  *int            `json:"-"`
+	Decider    *User           `json:"decider"`
+	Likes      *UserConnection `json:"likes"`
+	LikeCount  int             `json:"likeCount"`
+}
+
+func (Rule) IsNode() {}
+
+type Tag struct {
+	ID   int    `json:"-"`
+	Name string `json:"name"`
+}
+
+// Comment's Body is nil once it's deleted.
+type Comment struct {
+	ID       int     `json:"-"`
+	RuleID   int     `json:"-"`
+	UserID   int     `json:"-"`
+	ParentID *int    `json:"-"`
+	Created  string  `json:"created"`
+	Updated  *string `json:"updated"`
+	Deleted  *string `json:"deleted"`
+	Body     *string `json:"body"`
+}
+
+func (Comment) IsNode() {}
+
+type RuleRevision struct {
+	Number   int     `json:"number"`
+	EditorID int     `json:"-"`
+	Editor   *User   `json:"editor"`
+	Created  string  `json:"created"`
+	Summary  string  `json:"summary"`
+	Detail   *string `json:"detail"`
+}
+
+type Notification struct {
+	ID      int              `json:"-"`
+	Kind    NotificationKind `json:"kind"`
+	RuleID  int              `json:"-"`
+	ActorID int              `json:"-"`
+	Count   int              `json:"count"`
+	Created string           `json:"created"`
+	Updated string           `json:"updated"`
+	Read    *string          `json:"read"`
+}
+
+type Webhook struct {
+	ID      int            `json:"-"`
+	URL     string         `json:"url"`
+	Events  []WebhookEvent `json:"events"`
+	Active  bool           `json:"active"`
+	Created string         `json:"created"`
+}
+
+type WebhookDelivery struct {
+	ID          int                   `json:"-"`
+	Event       WebhookEvent          `json:"event"`
+	Payload     string                `json:"payload"`
+	Created     string                `json:"created"`
+	Status      WebhookDeliveryStatus `json:"status"`
+	Attempts    int                   `json:"attempts"`
+	NextAttempt *string               `json:"nextAttempt"`
+	LastStatus  *int                  `json:"lastStatus"`
+	LastError   *string               `json:"lastError"`
+	Delivered   *string               `json:"delivered"`
+}
+
+type LikesChange struct {
+	RuleID GlobalID `json:"ruleId"`
+	UserID int      `json:"-"`
+	User   *User    `json:"user"`
+	Liked  bool     `json:"liked"`
+}
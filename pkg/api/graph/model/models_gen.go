@@ -2,45 +2,480 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type Node interface {
+	IsNode()
+}
+
+type CommentConnection struct {
+	Edges    []*CommentEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+}
+
+type CommentEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Comment `json:"node"`
+}
+
+type DiffChunk struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
 type LikesUpdate struct {
-	Added   []int `json:"added"`
-	Removed []int `json:"removed"`
+	Added   []*GlobalID `json:"added"`
+	Removed []*GlobalID `json:"removed"`
+}
+
+type NotificationConnection struct {
+	Edges    []*NotificationEdge `json:"edges"`
+	PageInfo *PageInfo           `json:"pageInfo"`
+}
+
+type NotificationEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *Notification `json:"node"`
+}
+
+type NotificationPreference struct {
+	Kind    NotificationKind `json:"kind"`
+	Enabled bool             `json:"enabled"`
 }
 
 type PageInfo struct {
-	HasPreviousPage bool `json:"hasPreviousPage"`
-	HasNextPage     bool `json:"hasNextPage"`
-	StartCursor     *int `json:"startCursor"`
-	EndCursor       *int `json:"endCursor"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	HasNextPage     bool    `json:"hasNextPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+type RevisionDiff struct {
+	From    int          `json:"from"`
+	To      int          `json:"to"`
+	Summary []*DiffChunk `json:"summary"`
+	Detail  []*DiffChunk `json:"detail"`
 }
 
-type Rule struct {
-	ID      int       `json:"id"`
-	User    *User     `json:"user"`
-	Created string    `json:"created"`
-	Summary string    `json:"summary"`
-	Detail  *string   `json:"detail"`
-	Likes   *UserPage `json:"likes"`
+type RuleConnection struct {
+	Edges    []*RuleEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
 }
 
-type RulePage struct {
-	Rules    []*Rule   `json:"rules"`
-	PageInfo *PageInfo `json:"pageInfo"`
+type RuleEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Rule  `json:"node"`
 }
 
-type User struct {
-	ID    int       `json:"id"`
-	Name  string    `json:"name"`
-	Rules *RulePage `json:"rules"`
-	Likes *RulePage `json:"likes"`
+type RuleFilter struct {
+	UserID        *GlobalID    `json:"userId"`
+	Status        []RuleStatus `json:"status"`
+	CreatedAfter  *string      `json:"createdAfter"`
+	CreatedBefore *string      `json:"createdBefore"`
+	LikedBy       *GlobalID    `json:"likedBy"`
+	Text          *string      `json:"text"`
+	Tags          []string     `json:"tags"`
+	TagMatch      *TagMatch    `json:"tagMatch"`
 }
 
-type UserPage struct {
-	Users    []*User   `json:"users"`
-	PageInfo *PageInfo `json:"pageInfo"`
+type RuleSearchConnection struct {
+	Edges    []*RuleSearchEdge `json:"edges"`
+	PageInfo *PageInfo         `json:"pageInfo"`
+}
+
+type RuleSearchEdge struct {
+	Cursor  string  `json:"cursor"`
+	Node    *Rule   `json:"node"`
+	Rank    float64 `json:"rank"`
+	Summary string  `json:"summary"`
+	Detail  *string `json:"detail"`
+}
+
+type TagConnection struct {
+	Edges    []*TagEdge `json:"edges"`
+	PageInfo *PageInfo  `json:"pageInfo"`
+}
+
+type TagEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Tag   `json:"node"`
+}
+
+type UserConnection struct {
+	Edges    []*UserEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+type UserEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *User  `json:"node"`
 }
 
 type UserToken struct {
-	Token     string `json:"token"`
-	ExpiresAt int    `json:"expiresAt"`
+	Token            string `json:"token"`
+	ExpiresAt        int    `json:"expiresAt"`
+	RefreshToken     string `json:"refreshToken"`
+	RefreshExpiresAt int    `json:"refreshExpiresAt"`
+}
+
+type WebhookDeliveryConnection struct {
+	Edges    []*WebhookDeliveryEdge `json:"edges"`
+	PageInfo *PageInfo              `json:"pageInfo"`
+}
+
+type WebhookDeliveryEdge struct {
+	Cursor string           `json:"cursor"`
+	Node   *WebhookDelivery `json:"node"`
+}
+
+type DiffOp string
+
+const (
+	DiffOpEqual  DiffOp = "EQUAL"
+	DiffOpInsert DiffOp = "INSERT"
+	DiffOpDelete DiffOp = "DELETE"
+)
+
+var AllDiffOp = []DiffOp{
+	DiffOpEqual,
+	DiffOpInsert,
+	DiffOpDelete,
+}
+
+func (e DiffOp) IsValid() bool {
+	switch e {
+	case DiffOpEqual, DiffOpInsert, DiffOpDelete:
+		return true
+	}
+	return false
+}
+
+func (e DiffOp) String() string {
+	return string(e)
+}
+
+func (e *DiffOp) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DiffOp(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DiffOp", str)
+	}
+	return nil
+}
+
+func (e DiffOp) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type NotificationKind string
+
+const (
+	NotificationKindRuleLiked   NotificationKind = "RULE_LIKED"
+	NotificationKindRuleCreated NotificationKind = "RULE_CREATED"
+)
+
+var AllNotificationKind = []NotificationKind{
+	NotificationKindRuleLiked,
+	NotificationKindRuleCreated,
+}
+
+func (e NotificationKind) IsValid() bool {
+	switch e {
+	case NotificationKindRuleLiked, NotificationKindRuleCreated:
+		return true
+	}
+	return false
+}
+
+func (e NotificationKind) String() string {
+	return string(e)
+}
+
+func (e *NotificationKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationKind", str)
+	}
+	return nil
+}
+
+func (e NotificationKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleMember    Role = "MEMBER"
+	RoleModerator Role = "MODERATOR"
+	RoleAdmin     Role = "ADMIN"
+	RoleDictator  Role = "DICTATOR"
+)
+
+var AllRole = []Role{
+	RoleMember,
+	RoleModerator,
+	RoleAdmin,
+	RoleDictator,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleMember, RoleModerator, RoleAdmin, RoleDictator:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type RuleOrder string
+
+const (
+	RuleOrderNewest    RuleOrder = "NEWEST"
+	RuleOrderOldest    RuleOrder = "OLDEST"
+	RuleOrderMostLiked RuleOrder = "MOST_LIKED"
+	RuleOrderTrending  RuleOrder = "TRENDING"
+)
+
+var AllRuleOrder = []RuleOrder{
+	RuleOrderNewest,
+	RuleOrderOldest,
+	RuleOrderMostLiked,
+	RuleOrderTrending,
+}
+
+func (e RuleOrder) IsValid() bool {
+	switch e {
+	case RuleOrderNewest, RuleOrderOldest, RuleOrderMostLiked, RuleOrderTrending:
+		return true
+	}
+	return false
+}
+
+func (e RuleOrder) String() string {
+	return string(e)
+}
+
+func (e *RuleOrder) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RuleOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RuleOrder", str)
+	}
+	return nil
+}
+
+func (e RuleOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type RuleStatus string
+
+const (
+	RuleStatusProposed RuleStatus = "PROPOSED"
+	RuleStatusRatified RuleStatus = "RATIFIED"
+	RuleStatusVetoed   RuleStatus = "VETOED"
+	RuleStatusRepealed RuleStatus = "REPEALED"
+)
+
+var AllRuleStatus = []RuleStatus{
+	RuleStatusProposed,
+	RuleStatusRatified,
+	RuleStatusVetoed,
+	RuleStatusRepealed,
+}
+
+func (e RuleStatus) IsValid() bool {
+	switch e {
+	case RuleStatusProposed, RuleStatusRatified, RuleStatusVetoed, RuleStatusRepealed:
+		return true
+	}
+	return false
+}
+
+func (e RuleStatus) String() string {
+	return string(e)
+}
+
+func (e *RuleStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RuleStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RuleStatus", str)
+	}
+	return nil
+}
+
+func (e RuleStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "ANY"
+	TagMatchAll TagMatch = "ALL"
+)
+
+var AllTagMatch = []TagMatch{
+	TagMatchAny,
+	TagMatchAll,
+}
+
+func (e TagMatch) IsValid() bool {
+	switch e {
+	case TagMatchAny, TagMatchAll:
+		return true
+	}
+	return false
+}
+
+func (e TagMatch) String() string {
+	return string(e)
+}
+
+func (e *TagMatch) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TagMatch(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TagMatch", str)
+	}
+	return nil
+}
+
+func (e TagMatch) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "FAILED"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusDelivered,
+	WebhookDeliveryStatusFailed,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusDelivered, WebhookDeliveryStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookEvent string
+
+const (
+	WebhookEventRuleCreated WebhookEvent = "RULE_CREATED"
+	WebhookEventRuleDeleted WebhookEvent = "RULE_DELETED"
+	WebhookEventRuleLiked   WebhookEvent = "RULE_LIKED"
+	WebhookEventRuleUnliked WebhookEvent = "RULE_UNLIKED"
+)
+
+var AllWebhookEvent = []WebhookEvent{
+	WebhookEventRuleCreated,
+	WebhookEventRuleDeleted,
+	WebhookEventRuleLiked,
+	WebhookEventRuleUnliked,
+}
+
+func (e WebhookEvent) IsValid() bool {
+	switch e {
+	case WebhookEventRuleCreated, WebhookEventRuleDeleted, WebhookEventRuleLiked, WebhookEventRuleUnliked:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEvent) String() string {
+	return string(e)
+}
+
+func (e *WebhookEvent) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEvent", str)
+	}
+	return nil
+}
+
+func (e WebhookEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
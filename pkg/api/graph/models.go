@@ -0,0 +1,195 @@
+package graph
+
+import (
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/diff"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/webhook"
+	"time"
+)
+
+func TimeOf(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.String()
+	return &s
+}
+
+func UserOf(row database.User) model.User {
+	return model.User{
+		ID:   row.ID,
+		Name: row.Name,
+		Role: model.Role(row.Role),
+	}
+}
+
+func UserConnectionOf(rows []database.User, cursor func(database.User) string, pageInfo *model.PageInfo) *model.UserConnection {
+	return &model.UserConnection{
+		Edges: MapPointersOf(rows, func(row database.User) model.UserEdge {
+			node := UserOf(row)
+			return model.UserEdge{Cursor: cursor(row), Node: &node}
+		}),
+		PageInfo: pageInfo,
+	}
+}
+
+func RuleOf(row database.Rule) model.Rule {
+	return model.Rule{
+		ID:         row.ID,
+		UserID:     row.UserID,
+		Created:    row.Created.String(),
+		Updated:    TimeOf(row.Updated),
+		Summary:    row.Summary,
+		Detail:     row.Detail,
+		Status:     model.RuleStatus(row.Status),
+		VotingEnds: TimeOf(row.VotingEnds),
+		Decided:    TimeOf(row.Decided),
+		DeciderID:  row.DeciderID,
+		LikeCount:  row.LikeCount,
+	}
+}
+
+func RuleConnectionOf(rows []database.Rule, cursor func(database.Rule) string, pageInfo *model.PageInfo) *model.RuleConnection {
+	return &model.RuleConnection{
+		Edges: MapPointersOf(rows, func(row database.Rule) model.RuleEdge {
+			node := RuleOf(row)
+			return model.RuleEdge{Cursor: cursor(row), Node: &node}
+		}),
+		PageInfo: pageInfo,
+	}
+}
+
+func CommentOf(row database.Comment) model.Comment {
+	comment := model.Comment{
+		ID:       row.ID,
+		RuleID:   row.RuleID,
+		UserID:   row.UserID,
+		ParentID: row.ParentID,
+		Created:  row.Created.String(),
+		Updated:  TimeOf(row.Updated),
+		Deleted:  TimeOf(row.Deleted),
+	}
+	if row.Deleted == nil {
+		comment.Body = &row.Body
+	}
+	return comment
+}
+
+func CommentConnectionOf(rows []database.Comment, cursor func(database.Comment) string, pageInfo *model.PageInfo) *model.CommentConnection {
+	return &model.CommentConnection{
+		Edges: MapPointersOf(rows, func(row database.Comment) model.CommentEdge {
+			node := CommentOf(row)
+			return model.CommentEdge{Cursor: cursor(row), Node: &node}
+		}),
+		PageInfo: pageInfo,
+	}
+}
+
+func TagOf(row database.Tag) model.Tag {
+	return model.Tag{
+		ID:   row.ID,
+		Name: row.Name,
+	}
+}
+
+func TagConnectionOf(rows []database.Tag, cursor func(database.Tag) string, pageInfo *model.PageInfo) *model.TagConnection {
+	return &model.TagConnection{
+		Edges: MapPointersOf(rows, func(row database.Tag) model.TagEdge {
+			node := TagOf(row)
+			return model.TagEdge{Cursor: cursor(row), Node: &node}
+		}),
+		PageInfo: pageInfo,
+	}
+}
+
+func NotificationOf(row database.Notification) model.Notification {
+	return model.Notification{
+		ID:      row.ID,
+		Kind:    model.NotificationKind(row.Kind),
+		RuleID:  row.RuleID,
+		ActorID: row.ActorID,
+		Count:   row.EventCount,
+		Created: row.Created.String(),
+		Updated: row.Updated.String(),
+		Read:    TimeOf(row.Read),
+	}
+}
+
+func NotificationConnectionOf(rows []database.Notification, cursor func(database.Notification) string, pageInfo *model.PageInfo) *model.NotificationConnection {
+	return &model.NotificationConnection{
+		Edges: MapPointersOf(rows, func(row database.Notification) model.NotificationEdge {
+			node := NotificationOf(row)
+			return model.NotificationEdge{Cursor: cursor(row), Node: &node}
+		}),
+		PageInfo: pageInfo,
+	}
+}
+
+func WebhookOf(row database.Webhook) model.Webhook {
+	return model.Webhook{
+		ID:      row.ID,
+		URL:     row.URL,
+		Events:  MapOf(webhook.EventsOf(row), func(e string) model.WebhookEvent { return model.WebhookEvent(e) }),
+		Active:  row.Active,
+		Created: row.Created.String(),
+	}
+}
+
+func WebhookDeliveryOf(row database.WebhookDelivery) model.WebhookDelivery {
+	delivery := model.WebhookDelivery{
+		ID:         row.ID,
+		Event:      model.WebhookEvent(row.Event),
+		Payload:    row.Payload,
+		Created:    row.Created.String(),
+		Status:     model.WebhookDeliveryStatusPending,
+		Attempts:   row.Attempts,
+		LastStatus: row.LastStatus,
+		LastError:  row.LastError,
+		Delivered:  TimeOf(row.Delivered),
+	}
+	switch {
+	case row.Delivered != nil:
+		delivery.Status = model.WebhookDeliveryStatusDelivered
+	case row.Attempts >= webhook.MaxAttempts:
+		delivery.Status = model.WebhookDeliveryStatusFailed
+	default:
+		delivery.NextAttempt = TimeOf(&row.NextAttempt)
+	}
+	return delivery
+}
+
+func WebhookDeliveryConnectionOf(rows []database.WebhookDelivery, cursor func(database.WebhookDelivery) string, pageInfo *model.PageInfo) *model.WebhookDeliveryConnection {
+	return &model.WebhookDeliveryConnection{
+		Edges: MapPointersOf(rows, func(row database.WebhookDelivery) model.WebhookDeliveryEdge {
+			node := WebhookDeliveryOf(row)
+			return model.WebhookDeliveryEdge{Cursor: cursor(row), Node: &node}
+		}),
+		PageInfo: pageInfo,
+	}
+}
+
+func RuleRevisionOf(row database.RuleRevision) model.RuleRevision {
+	return model.RuleRevision{
+		Number:   row.Number,
+		EditorID: row.EditorID,
+		Created:  row.Created.String(),
+		Summary:  row.Summary,
+		Detail:   row.Detail,
+	}
+}
+
+var diffOps = map[diff.Op]model.DiffOp{
+	diff.Equal:  model.DiffOpEqual,
+	diff.Insert: model.DiffOpInsert,
+	diff.Delete: model.DiffOpDelete,
+}
+
+func DiffChunksOf(chunks []diff.Chunk) []*model.DiffChunk {
+	return MapPointersOf(chunks, func(chunk diff.Chunk) model.DiffChunk {
+		return model.DiffChunk{
+			Op:   diffOps[chunk.Op],
+			Text: chunk.Text,
+		}
+	})
+}
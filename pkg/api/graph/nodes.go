@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"context"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/loader"
+)
+
+// RowIDsOf returns the row IDs of global IDs that must all be of type typ.
+// A nil list stays nil.
+func RowIDsOf(ids []*model.GlobalID, typ string) ([]int, error) {
+	if ids == nil {
+		return nil, nil
+	}
+	return MapOfError(ids, func(id *model.GlobalID) (int, error) { return id.Of(typ) })
+}
+
+// GlobalIDsOf returns the global IDs of rows of type typ.
+func GlobalIDsOf(ids []int, typ string) []*model.GlobalID {
+	return MapPointersOf(ids, func(id int) model.GlobalID { return model.GlobalID{Type: typ, ID: id} })
+}
+
+// LoadNode queues the load of any node by its global ID. The returned function
+// waits for the node, which is nil if it doesn't exist.
+func LoadNode(ctx context.Context, id model.GlobalID) func() (model.Node, error) {
+	loaders := loader.For(ctx)
+	switch id.Type {
+	case "User":
+		thunk := loaders.UserByID.LoadThunk(ctx, id.ID)
+		return func() (model.Node, error) {
+			row, err := thunk()
+			if err != nil || row == nil {
+				return nil, err
+			}
+			return UserOf(*row), nil
+		}
+	case "Rule":
+		thunk := loaders.RuleByID.LoadThunk(ctx, id.ID)
+		return func() (model.Node, error) {
+			row, err := thunk()
+			if err != nil || row == nil {
+				return nil, err
+			}
+			return RuleOf(*row), nil
+		}
+	case "Comment":
+		thunk := loaders.CommentByID.LoadThunk(ctx, id.ID)
+		return func() (model.Node, error) {
+			row, err := thunk()
+			if err != nil || row == nil {
+				return nil, err
+			}
+			return CommentOf(*row), nil
+		}
+	default:
+		return func() (model.Node, error) {
+			return nil, apierror.NotFound("unknown node type %s", id.Type)
+		}
+	}
+}
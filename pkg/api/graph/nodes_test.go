@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/client"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"testing"
+)
+
+type testNode struct {
+	Typename string `json:"__typename"`
+	ID       string
+	Name     string
+	Summary  string
+}
+
+func TestNode(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	bobID := s.signup("bob")
+	ruleID := s.createRule(alice, "rule")
+	query := `query($id: ID!) { node(id: $id) { __typename id ... on User { name } ... on Rule { summary } } }`
+
+	var resp struct{ Node *testNode }
+	s.post(query, &resp, client.Var("id", ruleID))
+	if resp.Node == nil || resp.Node.Typename != "Rule" || resp.Node.ID != ruleID || resp.Node.Summary != "rule" {
+		t.Errorf("expected the rule, got %+v", resp.Node)
+	}
+	s.post(query, &resp, client.Var("id", bobID))
+	if resp.Node == nil || resp.Node.Typename != "User" || resp.Node.Name != "bob" {
+		t.Errorf("expected bob, got %+v", resp.Node)
+	}
+	s.post(query, &resp, client.Var("id", model.GlobalID{Type: "Rule", ID: 999}.String()))
+	if resp.Node != nil {
+		t.Errorf("expected no node, got %+v", resp.Node)
+	}
+	err := s.Post(query, &resp, client.Var("id", "1"))
+	expectCode(t, err, "VALIDATION")
+	err = s.Post(query, &resp, client.Var("id", model.GlobalID{Type: "Widget", ID: 1}.String()))
+	expectCode(t, err, "NOT_FOUND")
+
+	// An ID of one type is refused where another's is expected.
+	var like map[string]interface{}
+	err = s.Post(`mutation($ids: [ID!]) { like(add: $ids) { added } }`, &like, alice, client.Var("ids", []string{bobID}))
+	expectCode(t, err, "VALIDATION")
+}
+
+func TestNodes(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	ruleID := s.createRule(alice, "rule")
+	missing := model.GlobalID{Type: "User", ID: 999}.String()
+
+	var resp struct{ Nodes []*testNode }
+	s.post(`query($ids: [ID!]!) { nodes(ids: $ids) { __typename id } }`, &resp,
+		client.Var("ids", []string{missing, ruleID, missing}))
+	if len(resp.Nodes) != 3 {
+		t.Fatalf("expected a node per ID, got %+v", resp.Nodes)
+	}
+	if resp.Nodes[0] != nil || resp.Nodes[2] != nil {
+		t.Errorf("expected missing nodes to be null, got %+v, %+v", resp.Nodes[0], resp.Nodes[2])
+	}
+	if resp.Nodes[1] == nil || resp.Nodes[1].ID != ruleID {
+		t.Errorf("expected the rule, got %+v", resp.Nodes[1])
+	}
+}
@@ -0,0 +1,122 @@
+package graph
+
+import (
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotifyRuleLiked notifies the authors of rules that have been liked. A rule's
+// unread notification counts everyone who has liked it since it was made.
+func NotifyRuleLiked(tx *gorm.DB, likes []database.Like) error {
+	if len(likes) == 0 {
+		return nil
+	}
+	var rules []database.Rule
+	err := tx.Select("id", "user_id").
+		Where("id IN ?", MapOf(likes, func(like database.Like) int { return like.RuleID })).
+		Where(notificationsEnabled("user_id", database.NotificationRuleLiked)).
+		Find(&rules).Error
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	authors := make(map[int]int, len(rules))
+	for _, rule := range rules {
+		authors[rule.ID] = rule.UserID
+	}
+	var rows []database.Notification
+	for _, like := range likes {
+		author, ok := authors[like.RuleID]
+		if !ok || author == like.UserID {
+			continue
+		}
+		rows = append(rows, database.Notification{
+			UserID:     author,
+			Kind:       database.NotificationRuleLiked,
+			SubjectID:  like.RuleID,
+			RuleID:     like.RuleID,
+			ActorID:    like.UserID,
+			EventCount: 1,
+			Created:    like.Created,
+			Updated:    like.Created,
+		})
+	}
+	return notify(tx, rows, clause.Expr{SQL: `(SELECT COUNT(*) FROM likes
+		WHERE likes.rule_id = notifications.rule_id
+		AND likes.user_id <> notifications.user_id
+		AND likes.created >= notifications.created)`})
+}
+
+// NotifyRuleCreated notifies the followers of a rule's author.
+func NotifyRuleCreated(tx *gorm.DB, rule database.Rule) error {
+	var followers []int
+	err := tx.Model(&database.Follow{}).
+		Where(&database.Follow{FolloweeID: rule.UserID}).
+		Where(notificationsEnabled("follower_id", database.NotificationRuleCreated)).
+		Pluck("follower_id", &followers).Error
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	rows := MapOf(followers, func(follower int) database.Notification {
+		return database.Notification{
+			UserID:     follower,
+			Kind:       database.NotificationRuleCreated,
+			SubjectID:  rule.UserID,
+			RuleID:     rule.ID,
+			ActorID:    rule.UserID,
+			EventCount: 1,
+			Created:    rule.Created,
+			Updated:    rule.Created,
+		}
+	})
+	return notify(tx, rows, clause.Expr{SQL: "notifications.event_count + 1"})
+}
+
+// notificationsEnabled selects the users in column who haven't turned off
+// notifications of a kind.
+func notificationsEnabled(column, kind string) clause.Expression {
+	return clause.Expr{
+		SQL:  "? NOT IN (SELECT user_id FROM notification_preferences WHERE kind = ? AND NOT enabled)",
+		Vars: []interface{}{clause.Column{Name: column}, kind},
+	}
+}
+
+// notify adds notifications, coalescing each into its user's unread
+// notification of the same kind and subject if there is one, in which case
+// the notification is of the latest event and count is its new event count.
+func notify(tx *gorm.DB, rows []database.Notification, count clause.Expression) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "user_id"}, {Name: "kind"}, {Name: "subject_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "read IS NULL"}}},
+		DoUpdates: append(clause.AssignmentColumns([]string{"rule_id", "actor_id", "updated"}),
+			clause.Assignment{Column: clause.Column{Name: "event_count"}, Value: count}),
+	}).Create(&rows).Error
+	if err != nil {
+		return fmt.Errorf("notify error: %w", err)
+	}
+	return nil
+}
+
+// NotificationMessage describes a notification, given the latest rule and
+// actor it's about.
+func NotificationMessage(n *model.Notification, rule database.Rule, actor database.User) string {
+	switch n.Kind {
+	case model.NotificationKindRuleLiked:
+		if n.Count == 1 {
+			return fmt.Sprintf("%s liked your rule %q", actor.Name, rule.Summary)
+		}
+		return fmt.Sprintf("%d people liked your rule %q", n.Count, rule.Summary)
+	case model.NotificationKindRuleCreated:
+		if n.Count == 1 {
+			return fmt.Sprintf("%s proposed a rule %q", actor.Name, rule.Summary)
+		}
+		return fmt.Sprintf("%s proposed %d rules", actor.Name, n.Count)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,489 @@
+package graph
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/loader"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// CursorOf returns the opaque cursor of a row in a page from the values of the
+// columns the page is ordered by.
+func CursorOf(values ...interface{}) (string, error) {
+	fields := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case int:
+			fields[i] = "i" + strconv.FormatInt(int64(v), 10)
+		case int64:
+			fields[i] = "i" + strconv.FormatInt(v, 10)
+		case float64:
+			fields[i] = "f" + strconv.FormatFloat(v, 'g', -1, 64)
+		case string:
+			fields[i] = "s" + v
+		case bool:
+			fields[i] = "b" + strconv.FormatBool(v)
+		case time.Time:
+			fields[i] = "t" + v.Format(time.RFC3339Nano)
+		case nil:
+			return "", fmt.Errorf("null cursor value")
+		default:
+			return "", fmt.Errorf("unsupported cursor value %T", v)
+		}
+	}
+	return cursorOfFields(fields), nil
+}
+
+// IDCursor returns the cursor of a row in a page ordered by ID alone.
+func IDCursor(id int) string {
+	return cursorOfFields([]string{"i" + strconv.Itoa(id)})
+}
+
+func cursorOfFields(fields []string) string {
+	b, _ := json.Marshal(fields)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseCursor returns the values a cursor was made from.
+func ParseCursor(cursor string) ([]interface{}, error) {
+	invalid := apierror.Invalid("invalid cursor %q", cursor)
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var fields []string
+	if err := json.Unmarshal(b, &fields); err != nil || len(fields) == 0 {
+		return nil, invalid
+	}
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		if f == "" {
+			return nil, invalid
+		}
+		var err error
+		switch f[0] {
+		case 'i':
+			values[i], err = strconv.ParseInt(f[1:], 10, 64)
+		case 'f':
+			values[i], err = strconv.ParseFloat(f[1:], 64)
+		case 's':
+			values[i] = f[1:]
+		case 'b':
+			values[i], err = strconv.ParseBool(f[1:])
+		case 't':
+			values[i], err = time.Parse(time.RFC3339Nano, f[1:])
+		default:
+			err = invalid
+		}
+		if err != nil {
+			return nil, invalid
+		}
+	}
+	return values, nil
+}
+
+// PageArgs validates the first and after arguments of a connection of rows
+// ordered by ID, returning the ID to read after and the page size.
+func PageArgs(first *int, after *string) (int, int, error) {
+	limit := DefaultPageSize
+	if first != nil {
+		if *first < 0 {
+			return 0, 0, apierror.Invalid("first must not be negative")
+		}
+		if *first > MaxPageSize {
+			return 0, 0, apierror.Invalid("first must be at most %d", MaxPageSize)
+		}
+		limit = *first
+	}
+	if after == nil {
+		return 0, limit, nil
+	}
+	values, err := ParseCursor(*after)
+	if err != nil {
+		return 0, 0, err
+	}
+	id, ok := values[0].(int64)
+	if len(values) != 1 || !ok {
+		return 0, 0, apierror.Invalid("invalid cursor %q", *after)
+	}
+	return int(id), limit, nil
+}
+
+// SortKey is a column that pages are ordered by. Its values must not be null.
+// Expr, if set, is an expression to sort by instead of a column of the table,
+// such as an aggregate of related rows, and Column is then only its name.
+type SortKey struct {
+	Column string
+	Expr   clause.Expression
+	Desc   bool
+}
+
+func (k SortKey) expr() interface{} {
+	if k.Expr != nil {
+		return k.Expr
+	}
+	return clause.Column{Table: clause.CurrentTable, Name: k.Column}
+}
+
+// PageReader reads a page of rows with keyset pagination. Rows are ordered by
+// Order, followed by the primary key to break ties, and a row's cursor holds
+// its values of those columns so that the next page continues from exactly
+// where the last ended, however rows are added or removed in between.
+//
+// Like a Relay connection, a page is the first rows after After and before
+// Before, or the last rows if Last is set.
+type PageReader[T any] struct {
+	Query *gorm.DB
+	Order []SortKey
+	// OrderAt, if set, returns the order as of a time instead of Order, for
+	// orders that change with time. Cursors carry the time so that every page
+	// is read as of the time the first was.
+	OrderAt func(time.Time) []SortKey
+	First   *int
+	After   *string
+	Last    *int
+	Before  *string
+
+	Rows        []T
+	HasPrevious bool
+	HasNext     bool
+
+	schema *schema.Schema
+	keys   []SortKey
+	at     time.Time
+	// exprs holds the values of the expression keys of each row, and cursors
+	// the cursor of each row, by its primary key.
+	exprs   map[interface{}][]interface{}
+	cursors map[interface{}]string
+}
+
+func (p *PageReader[T]) StartRow() *T {
+	if len(p.Rows) == 0 {
+		return nil
+	}
+	return &p.Rows[0]
+}
+
+func (p *PageReader[T]) EndRow() *T {
+	if len(p.Rows) == 0 {
+		return nil
+	}
+	return &p.Rows[len(p.Rows)-1]
+}
+
+// Read reads the page and whether there are rows either side of it in one
+// query. The rows beyond the end the page is read towards are found by reading
+// one row too many, and the rows beyond the cursor it's read from by a
+// subquery.
+func (p *PageReader[T]) Read() error {
+	if p.First != nil && p.Last != nil {
+		return apierror.Invalid("first and last can't be used together")
+	}
+	limit, backward := DefaultPageSize, p.Last != nil
+	switch {
+	case p.First != nil:
+		limit = *p.First
+	case p.Last != nil:
+		limit = *p.Last
+	}
+	if limit < 0 {
+		return apierror.Invalid("first and last must not be negative")
+	}
+	if limit > MaxPageSize {
+		return apierror.Invalid("first and last must be at most %d", MaxPageSize)
+	}
+	stmt := &gorm.Statement{DB: p.Query}
+	if err := stmt.Parse(new(T)); err != nil {
+		return fmt.Errorf("page schema error: %w", err)
+	}
+	p.schema = stmt.Schema
+	p.keys = p.Order
+	if p.OrderAt != nil {
+		if err := p.readAt(); err != nil {
+			return err
+		}
+		p.keys = p.OrderAt(p.at)
+	}
+	if pk := p.schema.PrioritizedPrimaryField; pk != nil && (len(p.keys) == 0 || p.keys[len(p.keys)-1].Column != pk.DBName) {
+		p.keys = append(append([]SortKey(nil), p.keys...), SortKey{Column: pk.DBName})
+	}
+
+	base := p.Query.Model(new(T)).Session(&gorm.Session{})
+	qry := base
+	var from clause.Expression
+	if p.After != nil {
+		after, err := p.seek(*p.After, false)
+		if err != nil {
+			return err
+		}
+		qry = qry.Where(after)
+		if !backward {
+			from = after
+		}
+	}
+	if p.Before != nil {
+		before, err := p.seek(*p.Before, true)
+		if err != nil {
+			return err
+		}
+		qry = qry.Where(before)
+		if backward {
+			from = before
+		}
+	}
+	sel := "?.*"
+	vars := []interface{}{clause.Table{Name: clause.CurrentTable}}
+	var order []string
+	for i, key := range p.keys {
+		if key.Expr != nil {
+			sel += fmt.Sprintf(", ? AS page_key_%d", i)
+			vars = append(vars, key.Expr)
+		}
+		if key.Desc != backward {
+			order = append(order, "? DESC")
+		} else {
+			order = append(order, "? ASC")
+		}
+	}
+	if from != nil {
+		sel += ", EXISTS (?) AS page_beyond"
+		vars = append(vars, base.Select("1").Where(clause.Not(from)).Limit(1))
+	}
+	orderVars := make([]interface{}, len(p.keys))
+	for i, key := range p.keys {
+		orderVars[i] = key.expr()
+	}
+	qry = qry.Select(sel, vars...).
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(order, ", "), Vars: orderVars}}).
+		Limit(limit + 1)
+
+	rows, more, err := p.scan(qry)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	ahead := len(rows) > limit
+	if ahead {
+		rows = rows[:limit]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+		p.HasPrevious, p.HasNext = ahead, more
+	} else {
+		p.HasPrevious, p.HasNext = more, ahead
+	}
+	p.Rows = rows
+	p.cursors = make(map[interface{}]string, len(rows))
+	for _, row := range rows {
+		rv := reflect.ValueOf(&row).Elem()
+		cursor, err := p.cursorOf(rv)
+		if err != nil {
+			return fmt.Errorf("page cursor error: %w", err)
+		}
+		p.cursors[p.primaryKey(rv)] = cursor
+	}
+	return nil
+}
+
+// readAt reads the time of the order from the cursor the page is read from,
+// or else takes the current time.
+func (p *PageReader[T]) readAt() error {
+	cursor := p.After
+	if cursor == nil {
+		cursor = p.Before
+	}
+	if cursor == nil {
+		p.at = time.Now().Round(0) // Drop monotonic clock reading.
+		return nil
+	}
+	values, err := ParseCursor(*cursor)
+	if err != nil {
+		return err
+	}
+	at, ok := values[0].(time.Time)
+	if !ok {
+		return apierror.Invalid("invalid cursor %q", *cursor)
+	}
+	p.at = at
+	return nil
+}
+
+// seek returns the condition for rows after a cursor in page order, or
+// before it if before is set.
+func (p *PageReader[T]) seek(cursor string, before bool) (clause.Expression, error) {
+	values, err := ParseCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if p.OrderAt != nil {
+		if at, ok := values[0].(time.Time); !ok || !at.Equal(p.at) {
+			return nil, apierror.Invalid("invalid cursor %q", cursor)
+		}
+		values = values[1:]
+	}
+	if len(values) != len(p.keys) {
+		return nil, apierror.Invalid("invalid cursor %q", cursor)
+	}
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+	var sql strings.Builder
+	var vars []interface{}
+	for i, key := range p.keys {
+		if i != 0 {
+			sql.WriteString(" OR ")
+		}
+		sql.WriteString("(")
+		for j := 0; j < i; j++ {
+			sql.WriteString("? = ? AND ")
+			vars = append(vars, p.keys[j].expr(), values[j])
+		}
+		if key.Desc != before {
+			sql.WriteString("? < ?)")
+		} else {
+			sql.WriteString("? > ?)")
+		}
+		vars = append(vars, key.expr(), values[i])
+	}
+	return clause.Expr{SQL: "(" + sql.String() + ")", Vars: vars}, nil
+}
+
+// scan reads rows of T and the columns selected alongside them, which gorm
+// can't scan into a struct embedding a generic T.
+func (p *PageReader[T]) scan(qry *gorm.DB) ([]T, bool, error) {
+	rows, err := qry.Rows()
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, false, err
+	}
+	var out []T
+	var beyond bool
+	p.exprs = make(map[interface{}][]interface{})
+	for rows.Next() {
+		var row T
+		rv := reflect.ValueOf(&row).Elem()
+		values := make([]interface{}, len(columns))
+		exprs := make([]interface{}, len(p.keys))
+		for i, column := range columns {
+			if column == "page_beyond" {
+				values[i] = &beyond
+			} else if key, ok := pageKeyIndex(column); ok && key < len(exprs) {
+				values[i] = &exprs[key]
+			} else if field := p.schema.LookUpField(column); field != nil && field.Readable {
+				values[i] = field.ReflectValueOf(qry.Statement.Context, rv).Addr().Interface()
+			} else {
+				values[i] = new(interface{})
+			}
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, false, err
+		}
+		p.exprs[p.primaryKey(rv)] = exprs
+		out = append(out, row)
+	}
+	return out, beyond, rows.Err()
+}
+
+func (p *PageReader[T]) Info() *model.PageInfo {
+	pageInfo := model.PageInfo{
+		HasPreviousPage: p.HasPrevious,
+		HasNextPage:     p.HasNext,
+	}
+	if len(p.Rows) != 0 {
+		start, end := p.Cursor(*p.StartRow()), p.Cursor(*p.EndRow())
+		pageInfo.StartCursor = &start
+		pageInfo.EndCursor = &end
+	}
+	return &pageInfo
+}
+
+func pageKeyIndex(column string) (int, bool) {
+	if !strings.HasPrefix(column, "page_key_") {
+		return 0, false
+	}
+	i, err := strconv.Atoi(strings.TrimPrefix(column, "page_key_"))
+	return i, err == nil
+}
+
+func (p *PageReader[T]) primaryKey(rv reflect.Value) interface{} {
+	return p.schema.PrioritizedPrimaryField.ReflectValueOf(p.Query.Statement.Context, rv).Interface()
+}
+
+// value returns a row's value of the ith sort key, which is an error if it's
+// null as rows can't be ordered after or before a null.
+func (p *PageReader[T]) value(rv reflect.Value, i int) (interface{}, error) {
+	if p.keys[i].Expr != nil {
+		v := p.exprs[p.primaryKey(rv)][i]
+		if v == nil {
+			return nil, fmt.Errorf("sort key %s is null", p.keys[i].Column)
+		}
+		return v, nil
+	}
+	v := p.schema.LookUpField(p.keys[i].Column).ReflectValueOf(p.Query.Statement.Context, rv)
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, fmt.Errorf("sort key %s is null", p.keys[i].Column)
+	}
+	return reflect.Indirect(v).Interface(), nil
+}
+
+// Value returns a row's value of the sort key named column, or nil if the
+// page isn't ordered by it.
+func (p *PageReader[T]) Value(row T, column string) interface{} {
+	for i, key := range p.keys {
+		if key.Column == column {
+			v, _ := p.value(reflect.ValueOf(&row).Elem(), i)
+			return v
+		}
+	}
+	return nil
+}
+
+func (p *PageReader[T]) cursorOf(rv reflect.Value) (string, error) {
+	var values []interface{}
+	if p.OrderAt != nil {
+		values = append(values, p.at)
+	}
+	for i := range p.keys {
+		v, err := p.value(rv, i)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, v)
+	}
+	return CursorOf(values...)
+}
+
+// Cursor returns the cursor of a row read by the page.
+func (p *PageReader[T]) Cursor(row T) string {
+	return p.cursors[p.primaryKey(reflect.ValueOf(&row).Elem())]
+}
+
+func PageInfoOf[T any](p *loader.Page[T], cursor func(T) string) *model.PageInfo {
+	pageInfo := model.PageInfo{
+		HasPreviousPage: p.HasPrevious,
+		HasNextPage:     p.HasNext,
+	}
+	if len(p.Rows) != 0 {
+		start, end := cursor(p.Rows[0]), cursor(p.Rows[len(p.Rows)-1])
+		pageInfo.StartCursor = &start
+		pageInfo.EndCursor = &end
+	}
+	return &pageInfo
+}
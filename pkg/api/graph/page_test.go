@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorOf(t *testing.T) {
+	at := time.Date(2022, 7, 1, 12, 0, 0, 5, time.UTC)
+	cursor, err := CursorOf(1, int64(2), 0.5, "s", true, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := ParseCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{int64(1), int64(2), 0.5, "s", true, at}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("expected %v, got %v", want, values)
+	}
+
+	for _, v := range []interface{}{nil, []byte("x"), uint(1)} {
+		if _, err := CursorOf(v); err == nil {
+			t.Errorf("%#v: expected an error", v)
+		}
+	}
+}
+
+func TestPageReaderNullSortKey(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	s.createRule(alice, "rule")
+
+	// Rules that were never updated have no time of update to page after.
+	page := PageReader[database.Rule]{
+		Query: s.DB,
+		Order: []SortKey{{Column: "updated"}},
+	}
+	if err := page.Read(); err == nil {
+		t.Error("expected a null sort key to be an error")
+	}
+}
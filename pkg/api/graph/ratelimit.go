@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/ratelimit"
+	"strings"
+	"time"
+)
+
+var (
+	LoginIPLimit         = ratelimit.Limit{Burst: 20, Every: time.Second * 6}
+	LoginAccountLimit    = ratelimit.Limit{Burst: 10, Every: time.Minute}
+	PasswordResetIPLimit = ratelimit.Limit{Burst: 5, Every: time.Minute}
+	// Each reset mails the account, so resets are limited to stop that being
+	// used to flood someone's inbox.
+	PasswordResetAccountLimit = ratelimit.Limit{Burst: 3, Every: time.Hour}
+	SignupIPLimit             = ratelimit.Limit{Burst: 5, Every: time.Minute * 10}
+)
+
+// RateLimit takes a token from the bucket of key.
+func (r *Resolver) RateLimit(ctx context.Context, key string, limit ratelimit.Limit) error {
+	wait, err := r.take(ctx, key, limit)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		return apierror.RateLimited(wait, "too many requests, try again later")
+	}
+	return nil
+}
+
+// RateLimitLogin limits logins both from each IP address and to each account,
+// whether or not it exists.
+func (r *Resolver) RateLimitLogin(ctx context.Context, email string) error {
+	wait, err := r.take(ctx, "login:ip:"+ratelimit.IPForContext(ctx), LoginIPLimit)
+	if err == nil && wait == 0 {
+		wait, err = r.take(ctx, "login:email:"+strings.ToLower(email), LoginAccountLimit)
+	}
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		return auth.LoginLimited(wait)
+	}
+	return nil
+}
+
+// RateLimitPasswordReset limits password resets both from each IP address and
+// to each account, whether or not it exists.
+func (r *Resolver) RateLimitPasswordReset(ctx context.Context, email string) error {
+	if err := r.RateLimit(ctx, "reset:ip:"+ratelimit.IPForContext(ctx), PasswordResetIPLimit); err != nil {
+		return err
+	}
+	return r.RateLimit(ctx, "reset:email:"+strings.ToLower(email), PasswordResetAccountLimit)
+}
+
+// take takes a token from the bucket of key, returning how long until it can
+// if it's empty.
+func (r *Resolver) take(ctx context.Context, key string, limit ratelimit.Limit) (time.Duration, error) {
+	if r.RateLimits == nil {
+		return 0, nil
+	}
+	wait, err := r.RateLimits.Take(ctx, key, limit)
+	if err != nil {
+		return 0, fmt.Errorf("rate limit error: %w", err)
+	}
+	return wait, nil
+}
@@ -2,6 +2,10 @@ package graph
 
 import (
 	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/pubsub"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/ratelimit"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/validate"
+	"time"
 )
 
 // This file will not be regenerated automatically.
@@ -9,5 +13,23 @@ import (
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	DB *database.DB
+	DB     *database.DB
+	PubSub pubsub.Broker
+	// BaseURL is where the web app is served, for links sent by mail.
+	BaseURL string
+	// VerifiedEmailRequired blocks creating rules and liking until a user has
+	// verified their email address.
+	VerifiedEmailRequired bool
+	// ProposalPeriod is how long proposed rules are open to votes before the
+	// dictator may ratify them.
+	ProposalPeriod time.Duration
+	// PasswordPolicy is enforced when passwords are set.
+	PasswordPolicy validate.PasswordPolicy
+	// RateLimits holds the buckets of rate limits, which aren't enforced if
+	// it's nil.
+	RateLimits ratelimit.Store
+	// AllowPrivateWebhooks allows webhooks to loopback and private addresses,
+	// which are otherwise refused so that webhooks can't reach services inside
+	// our network.
+	AllowPrivateWebhooks bool
 }
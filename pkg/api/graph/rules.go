@@ -0,0 +1,181 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+// TrendingPeriod is how far back likes count towards a rule trending.
+const TrendingPeriod = time.Hour * 24 * 7
+
+// DecideRule moves a rule from one status to another on behalf of the
+// dictator, whose role the schema checks. check may refuse the decision based
+// on the current rule.
+func (r *Resolver) DecideRule(ctx context.Context, id model.GlobalID, from, to string, check func(database.Rule, time.Time) error) (*model.Rule, error) {
+	userAuth := auth.ForContext(ctx)
+	ruleID, err := id.Of("Rule")
+	if err != nil {
+		return nil, err
+	}
+	var row database.Rule
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&database.Rule{ID: ruleID}).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apierror.NotFound("rule %s not found", id)
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		if row.Status != from {
+			return apierror.New(apierror.CodeConflict, "rule %s is %s, not %s", id, row.Status, from)
+		}
+		now := time.Now().Round(0) // Drop monotonic clock reading.
+		if check != nil {
+			if err := check(row, now); err != nil {
+				return err
+			}
+		}
+		// Guard against a concurrent decision on the same rule.
+		res := tx.Model(&row).Where("status = ?", from).Updates(map[string]interface{}{
+			"status":     to,
+			"decided":    now,
+			"decider_id": userAuth.UserID,
+		})
+		if res.Error != nil {
+			return fmt.Errorf("database error: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return apierror.New(apierror.CodeConflict, "rule %s is no longer %s", id, from)
+		}
+		row.Status = to
+		row.Decided = &now
+		row.DeciderID = &userAuth.UserID
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	rule := RuleOf(row)
+	return &rule, nil
+}
+
+// RuleFilterOf returns a scope selecting the rules that match a filter.
+func RuleFilterOf(filter *model.RuleFilter) (func(*gorm.DB) *gorm.DB, error) {
+	var conds []clause.Expression
+	if filter != nil {
+		if filter.UserID != nil {
+			id, err := filter.UserID.Of("User")
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, clause.Eq{Column: clause.Column{Table: "rules", Name: "user_id"}, Value: id})
+		}
+		if filter.Status != nil {
+			conds = append(conds, clause.IN{
+				Column: clause.Column{Table: "rules", Name: "status"},
+				Values: MapOf(filter.Status, func(s model.RuleStatus) interface{} { return string(s) }),
+			})
+		}
+		if filter.CreatedAfter != nil {
+			t, err := ParseTime(*filter.CreatedAfter)
+			if err != nil {
+				return nil, apierror.Invalid("invalid createdAfter, expected RFC 3339 time: %v", err)
+			}
+			conds = append(conds, clause.Gte{Column: clause.Column{Table: "rules", Name: "created"}, Value: t})
+		}
+		if filter.CreatedBefore != nil {
+			t, err := ParseTime(*filter.CreatedBefore)
+			if err != nil {
+				return nil, apierror.Invalid("invalid createdBefore, expected RFC 3339 time: %v", err)
+			}
+			conds = append(conds, clause.Lt{Column: clause.Column{Table: "rules", Name: "created"}, Value: t})
+		}
+		if filter.LikedBy != nil {
+			id, err := filter.LikedBy.Of("User")
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, clause.Expr{
+				SQL:  "rules.id IN (SELECT rule_id FROM likes WHERE user_id = ?)",
+				Vars: []interface{}{id},
+			})
+		}
+		if filter.Text != nil && *filter.Text != "" {
+			pattern := "%" + likeEscaper.Replace(strings.ToLower(*filter.Text)) + "%"
+			conds = append(conds, clause.Expr{
+				SQL:  `(LOWER(rules.summary) LIKE ? ESCAPE '\' OR LOWER(rules.detail) LIKE ? ESCAPE '\')`,
+				Vars: []interface{}{pattern, pattern},
+			})
+		}
+		if len(filter.Tags) != 0 {
+			names, err := TagNamesOf(filter.Tags)
+			if err != nil {
+				return nil, err
+			}
+			sql := "rules.id IN (SELECT rule_tags.rule_id FROM rule_tags JOIN tags ON tags.id = rule_tags.tag_id WHERE tags.name IN ?"
+			vars := []interface{}{names}
+			if filter.TagMatch != nil && *filter.TagMatch == model.TagMatchAll {
+				sql += " GROUP BY rule_tags.rule_id HAVING COUNT(*) = ?"
+				vars = append(vars, len(names))
+			}
+			conds = append(conds, clause.Expr{SQL: sql + ")", Vars: vars})
+		}
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		if len(conds) == 0 {
+			return db
+		}
+		return db.Where(clause.And(conds...))
+	}, nil
+}
+
+// timeStringLayout is the layout of time.Time.String(), which times are output
+// in.
+const timeStringLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// ParseTime parses an RFC 3339 time, or a time as output by the API so that
+// those can be passed back in.
+func ParseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+	// Drop any monotonic clock reading.
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
+	if t, err := time.Parse(timeStringLayout, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// RuleOrderOf returns the keys to sort rules by for an order.
+func RuleOrderOf(order model.RuleOrder, now time.Time) []SortKey {
+	switch order {
+	case model.RuleOrderNewest:
+		return []SortKey{{Column: "created", Desc: true}, {Column: "id", Desc: true}}
+	case model.RuleOrderMostLiked:
+		return []SortKey{{Column: "like_count", Desc: true}, {Column: "id", Desc: true}}
+	case model.RuleOrderTrending:
+		return []SortKey{{
+			Column: "trending_likes",
+			Expr: clause.Expr{
+				SQL:  "(SELECT COUNT(*) FROM likes WHERE likes.rule_id = rules.id AND likes.created > ?)",
+				Vars: []interface{}{now.Add(-TrendingPeriod)},
+			},
+			Desc: true,
+		}, {Column: "id", Desc: true}}
+	default:
+		return []SortKey{{Column: "created"}, {Column: "id"}}
+	}
+}
@@ -0,0 +1,340 @@
+package graph
+
+import (
+	"fmt"
+	"github.com/99designs/gqlgen/client"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type ruleConnection struct {
+	Edges []struct {
+		Cursor string
+		Node   struct{ ID, Summary, Created string }
+	}
+	PageInfo struct {
+		HasPreviousPage bool
+		HasNextPage     bool
+		StartCursor     *string
+		EndCursor       *string
+	}
+}
+
+func (c ruleConnection) summaries() []string {
+	summaries := make([]string, len(c.Edges))
+	for i, edge := range c.Edges {
+		summaries[i] = edge.Node.Summary
+	}
+	return summaries
+}
+
+const rulesQuery = `query($first: Int, $after: String, $last: Int, $before: String, $filter: RuleFilter, $orderBy: RuleOrder) {
+	rules(first: $first, after: $after, last: $last, before: $before, filter: $filter, orderBy: $orderBy) {
+		edges { cursor node { id summary created } }
+		pageInfo { hasPreviousPage hasNextPage startCursor endCursor }
+	}
+}`
+
+func (s *testServer) rules(options ...client.Option) ruleConnection {
+	s.t.Helper()
+	var resp struct{ Rules ruleConnection }
+	s.post(rulesQuery, &resp, options...)
+	return resp.Rules
+}
+
+func TestDeleteRule(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	bob := s.user("bob")
+	id := s.createRule(alice, "rule")
+	var like map[string]interface{}
+	s.post(`mutation($ids: [ID!]) { like(add: $ids) { added } }`, &like, bob, client.Var("ids", []string{id}))
+
+	// Only the author may delete a rule.
+	var resp struct{ DeleteRule *string }
+	s.post(`mutation($id: ID!) { deleteRule(id: $id) }`, &resp, bob, client.Var("id", id))
+	if resp.DeleteRule != nil {
+		t.Fatalf("expected no rule deleted by another user, got %s", *resp.DeleteRule)
+	}
+	s.post(`mutation($id: ID!) { deleteRule(id: $id) }`, &resp, alice, client.Var("id", id))
+	if resp.DeleteRule == nil || *resp.DeleteRule != id {
+		t.Fatalf("expected rule %s deleted, got %v", id, resp.DeleteRule)
+	}
+	var count int64
+	if err := s.DB.Model(&database.Like{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected likes of the rule deleted, got %d", count)
+	}
+	if rules := s.rules(); len(rules.Edges) != 0 {
+		t.Errorf("expected no rules, got %v", rules.summaries())
+	}
+}
+
+func TestLike(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	bob := s.user("bob")
+	id := s.createRule(alice, "rule")
+
+	type likesUpdate struct {
+		Like struct{ Added, Removed []string }
+	}
+	var resp likesUpdate
+	query := `mutation($add: [ID!], $remove: [ID!]) { like(add: $add, remove: $remove) { added removed } }`
+	s.post(query, &resp, bob, client.Var("add", []string{id}))
+	if !reflect.DeepEqual(resp.Like.Added, []string{id}) {
+		t.Errorf("expected %s added, got %v", id, resp.Like.Added)
+	}
+	// Liking again changes nothing.
+	s.post(query, &resp, bob, client.Var("add", []string{id}))
+	if len(resp.Like.Added) != 0 {
+		t.Errorf("expected nothing added, got %v", resp.Like.Added)
+	}
+
+	var rule struct {
+		Node struct {
+			LikeCount      int
+			ViewerHasLiked bool
+		}
+	}
+	nodeQuery := `query($id: ID!) { node(id: $id) { ... on Rule { likeCount viewerHasLiked } } }`
+	s.post(nodeQuery, &rule, bob, client.Var("id", id))
+	if rule.Node.LikeCount != 1 || !rule.Node.ViewerHasLiked {
+		t.Errorf("expected 1 like by the viewer, got %+v", rule.Node)
+	}
+	s.post(nodeQuery, &rule, alice, client.Var("id", id))
+	if rule.Node.LikeCount != 1 || rule.Node.ViewerHasLiked {
+		t.Errorf("expected 1 like by someone else, got %+v", rule.Node)
+	}
+
+	// Liking a missing rule likes nothing.
+	missing := model.GlobalID{Type: "Rule", ID: 999}.String()
+	err := s.Post(query, &resp, bob, client.Var("add", []string{id, missing}))
+	expectCode(t, err, "NOT_FOUND")
+
+	s.post(query, &resp, bob, client.Var("remove", []string{id}))
+	if !reflect.DeepEqual(resp.Like.Removed, []string{id}) {
+		t.Errorf("expected %s removed, got %v", id, resp.Like.Removed)
+	}
+	s.post(nodeQuery, &rule, bob, client.Var("id", id))
+	if rule.Node.LikeCount != 0 || rule.Node.ViewerHasLiked {
+		t.Errorf("expected no likes, got %+v", rule.Node)
+	}
+}
+
+func TestRulesPagination(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	var want []string
+	for i := 1; i <= 5; i++ {
+		summary := fmt.Sprintf("rule %d", i)
+		s.createRule(alice, summary)
+		want = append(want, summary)
+	}
+
+	// Forwards, two at a time.
+	var got []string
+	var after *string
+	for {
+		page := s.rules(client.Var("first", 2), client.Var("after", after))
+		got = append(got, page.summaries()...)
+		if page.PageInfo.HasPreviousPage != (after != nil) {
+			t.Errorf("after %v: expected hasPreviousPage %v", after, after != nil)
+		}
+		if !page.PageInfo.HasNextPage {
+			break
+		}
+		after = page.PageInfo.EndCursor
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v forwards, got %v", want, got)
+	}
+
+	// Backwards from the end.
+	last := s.rules(client.Var("last", 2))
+	if !reflect.DeepEqual(last.summaries(), want[3:]) || !last.PageInfo.HasPreviousPage || last.PageInfo.HasNextPage {
+		t.Errorf("expected last page %v, got %v %+v", want[3:], last.summaries(), last.PageInfo)
+	}
+	before := s.rules(client.Var("last", 2), client.Var("before", last.PageInfo.StartCursor))
+	if !reflect.DeepEqual(before.summaries(), want[1:3]) || !before.PageInfo.HasNextPage {
+		t.Errorf("expected page %v, got %v %+v", want[1:3], before.summaries(), before.PageInfo)
+	}
+
+	newest := s.rules(client.Var("first", 2), client.Var("orderBy", "NEWEST"))
+	if !reflect.DeepEqual(newest.summaries(), []string{"rule 5", "rule 4"}) {
+		t.Errorf("expected newest rules first, got %v", newest.summaries())
+	}
+
+	var resp map[string]interface{}
+	err := s.Post(rulesQuery, &resp, client.Var("first", -1))
+	expectCode(t, err, "VALIDATION")
+	err = s.Post(rulesQuery, &resp, client.Var("first", MaxPageSize+1))
+	expectCode(t, err, "VALIDATION")
+	err = s.Post(rulesQuery, &resp, client.Var("after", "garbage"))
+	expectCode(t, err, "VALIDATION")
+}
+
+func TestRulesFilter(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	bobID := s.signup("bob")
+	bobToken, _ := s.login("bob")
+	bob := bearer(bobToken)
+	dictator := s.userWithRole("dictator", database.RoleDictator)
+	s.createRule(alice, "alice's")
+	liked := s.createRule(alice, "liked")
+	ratified := s.createRule(bob, "bob's")
+	var resp map[string]interface{}
+	s.post(`mutation($ids: [ID!]) { like(add: $ids) { added } }`, &resp, bob, client.Var("ids", []string{liked, ratified}))
+	s.post(`mutation($ids: [ID!]) { like(add: $ids) { added } }`, &resp, alice, client.Var("ids", []string{liked}))
+	s.post(`mutation($id: ID!) { ratifyRule(id: $id) { id } }`, &resp, dictator, client.Var("id", ratified))
+
+	for _, test := range []struct {
+		name    string
+		options []client.Option
+		want    []string
+	}{
+		{"user", []client.Option{client.Var("filter", map[string]interface{}{"userId": bobID})}, []string{"bob's"}},
+		{"likedBy", []client.Option{client.Var("filter", map[string]interface{}{"likedBy": bobID})}, []string{"liked", "bob's"}},
+		{"status", []client.Option{client.Var("filter", map[string]interface{}{"status": []string{"PROPOSED"}})}, []string{"alice's", "liked"}},
+		{"text", []client.Option{client.Var("filter", map[string]interface{}{"text": "LIKED"})}, []string{"liked"}},
+		{"mostLiked", []client.Option{client.Var("orderBy", "MOST_LIKED")}, []string{"liked", "bob's", "alice's"}},
+	} {
+		if got := s.rules(test.options...).summaries(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestRulesFilterCreated(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	s.createRule(alice, "first")
+	s.createRule(alice, "second")
+	rules := s.rules()
+	if len(rules.Edges) != 2 {
+		t.Fatalf("expected 2 rules, got %v", rules.summaries())
+	}
+
+	// Times output by the API can be passed back in.
+	created := rules.Edges[1].Node.Created
+	after := s.rules(client.Var("filter", map[string]interface{}{"createdAfter": created}))
+	if !reflect.DeepEqual(after.summaries(), []string{"second"}) {
+		t.Errorf("expected rules created from %s, got %v", created, after.summaries())
+	}
+	before := s.rules(client.Var("filter", map[string]interface{}{"createdBefore": created}))
+	if !reflect.DeepEqual(before.summaries(), []string{"first"}) {
+		t.Errorf("expected rules created before %s, got %v", created, before.summaries())
+	}
+	none := s.rules(client.Var("filter", map[string]interface{}{"createdAfter": "2999-01-01T00:00:00Z"}))
+	if len(none.Edges) != 0 {
+		t.Errorf("expected no rules, got %v", none.summaries())
+	}
+
+	var resp map[string]interface{}
+	err := s.Post(rulesQuery, &resp, client.Var("filter", map[string]interface{}{"createdAfter": "yesterday"}))
+	expectCode(t, err, "VALIDATION")
+}
+
+func TestRulesTrendingPagination(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	bob := s.user("bob")
+	var ids []string
+	for i := 1; i <= 3; i++ {
+		ids = append(ids, s.createRule(alice, fmt.Sprintf("rule %d", i)))
+	}
+	var resp map[string]interface{}
+	s.post(`mutation($ids: [ID!]) { like(add: $ids) { added } }`, &resp, bob, client.Var("ids", ids[1:2]))
+
+	var got []string
+	var after *string
+	for {
+		page := s.rules(client.Var("first", 1), client.Var("after", after), client.Var("orderBy", "TRENDING"))
+		got = append(got, page.summaries()...)
+		if !page.PageInfo.HasNextPage {
+			break
+		}
+		after = page.PageInfo.EndCursor
+	}
+	if want := []string{"rule 2", "rule 3", "rule 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Cursors of other orders don't carry the time trending is as of.
+	newest := s.rules(client.Var("first", 1), client.Var("orderBy", "NEWEST"))
+	err := s.Post(rulesQuery, &resp, client.Var("after", newest.PageInfo.EndCursor), client.Var("orderBy", "TRENDING"))
+	expectCode(t, err, "VALIDATION")
+}
+
+func TestRuleDiff(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	id := s.createRule(alice, "no tabs")
+	var resp map[string]interface{}
+	s.post(`mutation($id: ID!) { updateRule(id: $id, summary: "no tabs or spaces") { id } }`, &resp, alice, client.Var("id", id))
+
+	var rule struct {
+		Node struct {
+			Revisions []struct{ Number int }
+			Diff      struct {
+				Summary []struct{ Op, Text string }
+			}
+		}
+	}
+	s.post(`query($id: ID!) { node(id: $id) { ... on Rule {
+		revisions { number }
+		diff(from: 1, to: 2) { summary { op text } }
+	} } }`, &rule, client.Var("id", id))
+	if len(rule.Node.Revisions) != 2 {
+		t.Errorf("expected 2 revisions, got %v", rule.Node.Revisions)
+	}
+	want := []struct{ Op, Text string }{{"EQUAL", "no tabs"}, {"INSERT", " or spaces"}}
+	if !reflect.DeepEqual(rule.Node.Diff.Summary, want) {
+		t.Errorf("expected diff %v, got %v", want, rule.Node.Diff.Summary)
+	}
+
+	// Only the author may edit a rule.
+	bob := s.user("bob")
+	err := s.Post(`mutation($id: ID!) { updateRule(id: $id, summary: "bob's") { id } }`, &resp, bob, client.Var("id", id))
+	expectCode(t, err, "NOT_FOUND")
+}
+
+func TestRuleDiffLarge(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	var create struct{ CreateRule struct{ ID string } }
+	s.post(`mutation($detail: String!) { createRule(summary: "rule", detail: $detail) { id } }`,
+		&create, alice, client.Var("detail", strings.Repeat("\n", 10000)))
+	id := create.CreateRule.ID
+	var resp map[string]interface{}
+	s.post(`mutation($id: ID!, $detail: String!) { updateRule(id: $id, summary: "rule", detail: $detail) { id } }`,
+		&resp, alice, client.Var("id", id), client.Var("detail", strings.Repeat("x\n", 5000)))
+
+	var rule struct {
+		Node struct {
+			Diff struct {
+				Detail []struct{ Op, Text string }
+			}
+		}
+	}
+	s.post(`query($id: ID!) { node(id: $id) { ... on Rule { diff(from: 1, to: 2) { detail { op text } } } } }`,
+		&rule, client.Var("id", id))
+	var from, to strings.Builder
+	for _, chunk := range rule.Node.Diff.Detail {
+		if chunk.Op != "INSERT" {
+			from.WriteString(chunk.Text)
+		}
+		if chunk.Op != "DELETE" {
+			to.WriteString(chunk.Text)
+		}
+	}
+	if from.Len() != 10000 || to.String() != strings.Repeat("x\n", 5000) {
+		t.Errorf("expected the diff to rebuild both revisions")
+	}
+}
@@ -4,22 +4,117 @@ package graph
 // will be copied through when generating and any unknown code will be moved to the end.
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"time"
 
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
 	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
 	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/diff"
 	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/generated"
 	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/loader"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/mail"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/pubsub"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/ratelimit"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/validate"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/webhook"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// ID is the resolver for the id field.
+func (r *commentResolver) ID(ctx context.Context, obj *model.Comment) (*model.GlobalID, error) {
+	return &model.GlobalID{Type: "Comment", ID: obj.ID}, nil
+}
+
+// Rule is the resolver for the rule field.
+func (r *commentResolver) Rule(ctx context.Context, obj *model.Comment) (*model.Rule, error) {
+	row, err := loader.For(ctx).RuleByID.Load(ctx, obj.RuleID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, apierror.NotFound("rule %d not found", obj.RuleID)
+	}
+	rule := RuleOf(*row)
+	return &rule, nil
+}
+
+// User is the resolver for the user field.
+func (r *commentResolver) User(ctx context.Context, obj *model.Comment) (*model.User, error) {
+	// A deleted comment is anonymous.
+	if obj.Deleted != nil {
+		return nil, nil
+	}
+	row, err := loader.For(ctx).UserByID.Load(ctx, obj.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, apierror.NotFound("user %d not found", obj.UserID)
+	}
+	user := UserOf(*row)
+	return &user, nil
+}
+
+// Parent is the resolver for the parent field.
+func (r *commentResolver) Parent(ctx context.Context, obj *model.Comment) (*model.Comment, error) {
+	if obj.ParentID == nil {
+		return nil, nil
+	}
+	row, err := loader.For(ctx).CommentByID.Load(ctx, *obj.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, apierror.NotFound("comment %d not found", *obj.ParentID)
+	}
+	parent := CommentOf(*row)
+	return &parent, nil
+}
+
+// Replies is the resolver for the replies field.
+func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment, first *int, after *string) (*model.CommentConnection, error) {
+	page := PageReader[database.Comment]{
+		Query: r.DB.WithContext(ctx).Where(&database.Comment{ParentID: &obj.ID}),
+		First: first,
+		After: after,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return CommentConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// User is the resolver for the user field.
+func (r *likesChangeResolver) User(ctx context.Context, obj *model.LikesChange) (*model.User, error) {
+	row, err := loader.For(ctx).UserByID.Load(ctx, obj.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, apierror.NotFound("user %d not found", obj.UserID)
+	}
+	user := UserOf(*row)
+	return &user, nil
+}
+
 // UserCreate is the resolver for the userCreate field.
 func (r *mutationResolver) CreateUser(ctx context.Context, name string, email string, password string) (*model.User, error) {
+	if err := r.RateLimit(ctx, "signup:ip:"+ratelimit.IPForContext(ctx), SignupIPLimit); err != nil {
+		return nil, err
+	}
+	if err := validate.NotBlank("name", name); err != nil {
+		return nil, err
+	}
+	if err := r.PasswordPolicy.Check("password", password); err != nil {
+		return nil, err
+	}
 	// Prepare password.
 	key, salt, err := auth.Encode([]byte(password))
 	if err != nil {
@@ -29,103 +124,281 @@ func (r *mutationResolver) CreateUser(ctx context.Context, name string, email st
 	row := database.User{
 		Name:  name,
 		Email: email,
+		Role:  database.RoleMember,
 		Key:   key,
 		Salt:  salt,
 	}
-	// TODO: check for unique email first?
-	if err := r.DB.WithContext(ctx).Create(&row).Error; err != nil {
-		return nil, fmt.Errorf("database error: %v", err)
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&row).Error; err != nil {
+			if conflict := ConflictOf(err); conflict != nil {
+				return conflict
+			}
+			return fmt.Errorf("database error: %v", err)
+		}
+		return r.SendEmailVerification(tx, row, row.Email)
+	}); err != nil {
+		return nil, err
 	}
-	return &model.User{
-		ID:   row.ID,
-		Name: row.Name,
-	}, nil
+	user := UserOf(row)
+	return &user, nil
 }
 
 // UserUpdate is the resolver for the userUpdate field.
-func (r *mutationResolver) UpdateUser(ctx context.Context, name *string) (*model.User, error) {
+func (r *mutationResolver) UpdateUser(ctx context.Context, name *string, email *string) (*model.User, error) {
 	userAuth := auth.ForContext(ctx)
-	if userAuth == nil {
-		return nil, fmt.Errorf("unauthorized")
-	}
-	user := database.User{
-		ID: userAuth.UserID,
-	}
-	do := false
 	if name != nil {
-		user.Name = *name
-		do = true
+		if err := validate.NotBlank("name", *name); err != nil {
+			return nil, err
+		}
 	}
-	if do {
-		if err := r.DB.WithContext(ctx).Updates(&user).Error; err != nil {
-			return nil, fmt.Errorf("database error: %w", err)
+	var user database.User
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&database.User{ID: userAuth.UserID}).First(&user).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
 		}
+		if name != nil && *name != user.Name {
+			user.Name = *name
+			if err := tx.Model(&user).Update("name", user.Name).Error; err != nil {
+				if conflict := ConflictOf(err); conflict != nil {
+					return conflict
+				}
+				return fmt.Errorf("database error: %w", err)
+			}
+		}
+		// A new address only replaces the current one once it's verified.
+		if email != nil && *email != user.Email {
+			return r.SendEmailVerification(tx, user, *email)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
-	return &model.User{
-		ID:   user.ID,
-		Name: user.Name,
-	}, nil
+	out := UserOf(user)
+	return &out, nil
 }
 
 // UserLogin is the resolver for the userLogin field.
 func (r *mutationResolver) Login(ctx context.Context, email string, password string) (*model.UserToken, error) {
-	user := database.User{Email: email}
-	if err := r.DB.WithContext(ctx).Where(&user).First(&user).Error; err != nil {
-		switch err {
-		case gorm.ErrRecordNotFound:
-			err = fmt.Errorf("user %s not found", email)
-		default:
-			err = fmt.Errorf("database error: %w", err)
-		}
+	if err := r.RateLimitLogin(ctx, email); err != nil {
 		return nil, err
 	}
-	key := auth.Key([]byte(password), user.Salt)
-	if bytes.Compare(key, user.Key) != 0 {
-		return nil, fmt.Errorf("password error")
+	userID, err := auth.Login(r.DB.WithContext(ctx), email, password)
+	if err != nil {
+		return nil, err
 	}
-	token, expiresAt, err := auth.Token(user.ID, time.Hour*24)
+	token, err := auth.OpenSession(r.DB.WithContext(ctx), userID)
 	if err != nil {
 		return nil, fmt.Errorf("token error: %w", err)
 	}
-	return &model.UserToken{
-		Token:     token,
-		ExpiresAt: expiresAt,
-	}, nil
+	return UserTokenOf(token), nil
+}
+
+// Refresh is the resolver for the refresh field.
+func (r *mutationResolver) Refresh(ctx context.Context, refreshToken string) (*model.UserToken, error) {
+	token, err := auth.RefreshSession(r.DB.WithContext(ctx), refreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshReused) {
+			log.Printf("refresh token reuse detected, session revoked")
+		}
+		return nil, fmt.Errorf("token error: %w", err)
+	}
+	return UserTokenOf(token), nil
+}
+
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context) (bool, error) {
+	userAuth := auth.ForContext(ctx)
+	ok, err := auth.RevokeSession(r.DB.WithContext(ctx), userAuth.UserID, userAuth.SessionID)
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// LogoutAllSessions is the resolver for the logoutAllSessions field.
+func (r *mutationResolver) LogoutAllSessions(ctx context.Context) (int, error) {
+	userAuth := auth.ForContext(ctx)
+	return auth.RevokeUserSessions(r.DB.WithContext(ctx), userAuth.UserID)
+}
+
+// RequestPasswordReset is the resolver for the requestPasswordReset field.
+func (r *mutationResolver) RequestPasswordReset(ctx context.Context, email string) (bool, error) {
+	if err := r.RateLimitPasswordReset(ctx, email); err != nil {
+		return false, err
+	}
+	user := database.User{Email: email}
+	if err := r.DB.WithContext(ctx).Where(&user).Find(&user).Error; err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+	// Respond the same whether or not the account exists, so this can't be
+	// used to find out who has one.
+	if user.ID == 0 {
+		return true, nil
+	}
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := auth.CreatePasswordReset(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		link := fmt.Sprintf("%s/reset-password?token=%s", r.BaseURL, url.QueryEscape(token))
+		return mail.Enqueue(tx, mail.PasswordReset(user.Email, user.Name, link))
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ResetPassword is the resolver for the resetPassword field.
+func (r *mutationResolver) ResetPassword(ctx context.Context, token string, newPassword string) (bool, error) {
+	if err := r.PasswordPolicy.Check("newPassword", newPassword); err != nil {
+		return false, err
+	}
+	if _, err := auth.ResetPassword(r.DB.WithContext(ctx), token, newPassword); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// VerifyEmail is the resolver for the verifyEmail field.
+func (r *mutationResolver) VerifyEmail(ctx context.Context, token string) (bool, error) {
+	if _, err := auth.VerifyEmail(r.DB.WithContext(ctx), token); err != nil {
+		// Another account may have taken the address since it was requested.
+		if conflict := ConflictOf(err); conflict != nil {
+			return false, conflict
+		}
+		return false, err
+	}
+	return true, nil
 }
 
 // RuleCreate is the resolver for the ruleCreate field.
 func (r *mutationResolver) CreateRule(ctx context.Context, summary string, detail *string) (*model.Rule, error) {
 	userAuth := auth.ForContext(ctx)
-	if userAuth == nil {
-		return nil, fmt.Errorf("unauthorized")
+	if err := validate.NotBlank("summary", summary); err != nil {
+		return nil, err
+	}
+	if err := r.CheckEmailVerified(ctx, userAuth.UserID); err != nil {
+		return nil, err
 	}
+	now := time.Now().Round(0) // Drop monotonic clock reading.
+	votingEnds := now.Add(r.ProposalPeriod)
 	row := database.Rule{
-		UserID:  userAuth.UserID,
-		Created: time.Now(),
-		Summary: summary,
-		Detail:  detail,
+		UserID:     userAuth.UserID,
+		Created:    now,
+		Summary:    summary,
+		Detail:     detail,
+		Status:     database.RuleProposed,
+		VotingEnds: &votingEnds,
 	}
-	if err := r.DB.WithContext(ctx).Create(&row).Error; err != nil {
-		return nil, fmt.Errorf("database error: %v", err)
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&row).Error; err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+		revision := database.RuleRevision{
+			RuleID:   row.ID,
+			Number:   1,
+			EditorID: row.UserID,
+			Created:  row.Created,
+			Summary:  row.Summary,
+			Detail:   row.Detail,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return fmt.Errorf("database error: %v", err)
+		}
+		if err := webhook.Enqueue(tx, webhook.RuleCreated, WebhookRuleOf(row)); err != nil {
+			return err
+		}
+		return NotifyRuleCreated(tx, row)
+	}); err != nil {
+		return nil, err
 	}
-	return &model.Rule{
-		ID:      row.ID,
-		Created: row.Created.String(),
-		Summary: row.Summary,
-	}, nil
+	r.Publish(ctx, pubsub.RuleCreated, pubsub.RuleEvent{RuleID: row.ID})
+	rule := RuleOf(row)
+	return &rule, nil
+}
+
+// UpdateRule is the resolver for the updateRule field.
+func (r *mutationResolver) UpdateRule(ctx context.Context, id model.GlobalID, summary string, detail *string) (*model.Rule, error) {
+	userAuth := auth.ForContext(ctx)
+	if err := validate.NotBlank("summary", summary); err != nil {
+		return nil, err
+	}
+	ruleID, err := id.Of("Rule")
+	if err != nil {
+		return nil, err
+	}
+	var row database.Rule
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&database.Rule{ID: ruleID, UserID: userAuth.UserID}).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apierror.NotFound("rule %s not found", id)
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		// Only proposals may be edited, a decided rule's text is final.
+		if row.Status != database.RuleProposed {
+			return apierror.New(apierror.CodeConflict, "rule %s is %s and can't be edited", id, row.Status)
+		}
+		var latest database.RuleRevision
+		if err := tx.Where(&database.RuleRevision{RuleID: ruleID}).Order("number DESC").First(&latest).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		now := time.Now().Round(0) // Drop monotonic clock reading.
+		revision := database.RuleRevision{
+			RuleID:   ruleID,
+			Number:   latest.Number + 1,
+			EditorID: userAuth.UserID,
+			Created:  now,
+			Summary:  summary,
+			Detail:   detail,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		row.Summary = summary
+		row.Detail = detail
+		row.Updated = &now
+		err := tx.Model(&row).Select("summary", "detail", "updated").Updates(&row).Error
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	rule := RuleOf(row)
+	return &rule, nil
 }
 
 // RuleDelete is the resolver for the ruleDelete field.
-func (r *mutationResolver) DeleteRule(ctx context.Context, id int) (*int, error) {
+func (r *mutationResolver) DeleteRule(ctx context.Context, id model.GlobalID) (*model.GlobalID, error) {
 	userAuth := auth.ForContext(ctx)
-	if userAuth == nil {
-		return nil, fmt.Errorf("unauthorized")
+	ruleID, err := id.Of("Rule")
+	if err != nil {
+		return nil, err
 	}
 	var rows []database.Rule
-	err := r.DB.WithContext(ctx).
-		Clauses(clause.Returning{}).
-		Where(&database.Rule{ID: id, UserID: userAuth.UserID}).
-		Delete(&rows).Error
+	err = r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rule := database.Rule{ID: ruleID, UserID: userAuth.UserID}
+		// Moderators may delete anyone's rules.
+		if auth.HasRole(userAuth.Role, database.RoleModerator) {
+			rule.UserID = 0
+		}
+		var count int64
+		if err := tx.Model(&rule).Where(&rule).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if err := tx.Where(&database.Like{RuleID: ruleID}).Delete(&database.Like{}).Error; err != nil {
+			return fmt.Errorf("remove likes error: %w", err)
+		}
+		if err := tx.Clauses(clause.Returning{}).Where(&rule).Delete(&rows).Error; err != nil {
+			return err
+		}
+		return webhook.Enqueue(tx, webhook.RuleDeleted, MapOf(rows, WebhookRuleOf)...)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -133,52 +406,99 @@ func (r *mutationResolver) DeleteRule(ctx context.Context, id int) (*int, error)
 	case 0:
 		return nil, nil
 	case 1:
-		return &rows[0].ID, nil
+		r.Publish(ctx, pubsub.RuleDeleted, pubsub.RuleEvent{RuleID: rows[0].ID})
+		return &id, nil
 	default:
-		log.Printf("deleted multiple rules with id=%d (impossible!): %v", id, rows)
-		return &rows[0].ID, nil
+		log.Printf("deleted multiple rules with id=%d (impossible!): %v", ruleID, rows)
+		return &id, nil
 	}
 }
 
+// RatifyRule is the resolver for the ratifyRule field.
+func (r *mutationResolver) RatifyRule(ctx context.Context, id model.GlobalID) (*model.Rule, error) {
+	return r.DecideRule(ctx, id, database.RuleProposed, database.RuleRatified, func(rule database.Rule, now time.Time) error {
+		if rule.VotingEnds != nil && now.Before(*rule.VotingEnds) {
+			return apierror.New(apierror.CodeConflict, "rule %s is open to votes until %s", id, *rule.VotingEnds)
+		}
+		return nil
+	})
+}
+
+// VetoRule is the resolver for the vetoRule field.
+func (r *mutationResolver) VetoRule(ctx context.Context, id model.GlobalID) (*model.Rule, error) {
+	return r.DecideRule(ctx, id, database.RuleProposed, database.RuleVetoed, nil)
+}
+
+// RepealRule is the resolver for the repealRule field.
+func (r *mutationResolver) RepealRule(ctx context.Context, id model.GlobalID) (*model.Rule, error) {
+	return r.DecideRule(ctx, id, database.RuleRatified, database.RuleRepealed, nil)
+}
+
 // LikesUpdate is the resolver for the likesUpdate field.
-func (r *mutationResolver) Like(ctx context.Context, add []int, remove []int) (*model.LikesUpdate, error) {
+func (r *mutationResolver) Like(ctx context.Context, add []*model.GlobalID, remove []*model.GlobalID) (*model.LikesUpdate, error) {
 	userAuth := auth.ForContext(ctx)
-	if userAuth == nil {
-		return nil, fmt.Errorf("unauthorized")
+	if err := r.CheckEmailVerified(ctx, userAuth.UserID); err != nil {
+		return nil, err
+	}
+	addIDs, err := RowIDsOf(add, "Rule")
+	if err != nil {
+		return nil, err
+	}
+	removeIDs, err := RowIDsOf(remove, "Rule")
+	if err != nil {
+		return nil, err
 	}
 
 	addMap := make(map[int]struct{})
-	for _, id := range add {
+	for _, id := range addIDs {
 		addMap[id] = struct{}{}
 	}
 
-	var conflicts []int
-	for _, id := range remove {
+	var conflicts []string
+	for i, id := range removeIDs {
 		if _, ok := addMap[id]; ok {
-			conflicts = append(conflicts, id)
+			conflicts = append(conflicts, remove[i].String())
 		}
 	}
 	if len(conflicts) != 0 {
-		return nil, fmt.Errorf("request both add/remove ids=%v", conflicts)
+		return nil, apierror.Invalid("request both add/remove ids=%v", conflicts)
 	}
 
 	var update model.LikesUpdate
 
 	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().Round(0) // Drop monotonic clock reading.
 		toRows := func(ids []int) []database.Like {
 			likes := make([]database.Like, len(ids))
 			for i, id := range ids {
 				likes[i].UserID = userAuth.UserID
 				likes[i].RuleID = id
+				likes[i].Created = now
 			}
 			return likes
 		}
-		fromRows := func(rows []database.Like) []int {
+		fromRows := func(rows []database.Like) []*model.GlobalID {
 			ids := make([]int, len(rows))
 			for i, row := range rows {
 				ids[i] = row.RuleID
 			}
-			return ids
+			return GlobalIDsOf(ids, "Rule")
+		}
+		countLikes := func(rows []database.Like, delta int) error {
+			if len(rows) == 0 {
+				return nil
+			}
+			ids := make([]int, len(rows))
+			for i, row := range rows {
+				ids[i] = row.RuleID
+			}
+			err := tx.Model(&database.Rule{}).
+				Where("id IN ?", ids).
+				UpdateColumn("like_count", gorm.Expr("like_count + ?", delta)).Error
+			if err != nil {
+				return fmt.Errorf("like count error: %w", err)
+			}
+			return nil
 		}
 		filterRows := func(rows []database.Like, filter func(row database.Like) bool) []database.Like {
 			var out []database.Like
@@ -190,18 +510,30 @@ func (r *mutationResolver) Like(ctx context.Context, add []int, remove []int) (*
 			return out
 		}
 
-		if add != nil {
-			addRows := toRows(add)
+		if addIDs != nil {
+			var found []int
+			if err := tx.Model(&database.Rule{}).Where("id IN ?", addIDs).Pluck("id", &found).Error; err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+			exists := make(map[int]struct{}, len(found))
+			for _, id := range found {
+				exists[id] = struct{}{}
+			}
+			for i, id := range addIDs {
+				if _, ok := exists[id]; !ok {
+					return apierror.NotFound("rule %s not found", add[i])
+				}
+			}
+
+			addRows := toRows(addIDs)
 
 			// TODO: Is there a way to make INSERT ... ON CONFLICT DO NOTHING
 			//  return only newly inserted rows?
 			var existRows []database.Like
-			// TODO: Is there a way to do this with WHERE ... IN using structs?
-			qry := tx
-			for _, row := range addRows {
-				qry = qry.Or(row)
-			}
-			if err := qry.Find(&existRows).Error; err != nil {
+			err := tx.
+				Where("user_id = ? AND rule_id IN ?", userAuth.UserID, addIDs).
+				Find(&existRows).Error
+			if err != nil {
 				return fmt.Errorf("find existing likes error: %w", err)
 			}
 			if len(existRows) != 0 {
@@ -217,15 +549,34 @@ func (r *mutationResolver) Like(ctx context.Context, add []int, remove []int) (*
 
 			if len(addRows) > 0 {
 				if err := tx.Create(&addRows).Error; err != nil {
-					return fmt.Errorf("add likes error: %w", err)
+					return fmt.Errorf("database error: %w", err)
+				}
+				if err := countLikes(addRows, 1); err != nil {
+					return err
+				}
+				if err := NotifyRuleLiked(tx, addRows); err != nil {
+					return err
+				}
+				if err := webhook.Enqueue(tx, webhook.RuleLiked, MapOf(addRows, WebhookLikeOf)...); err != nil {
+					return err
 				}
 			}
 			update.Added = fromRows(addRows)
 		}
-		if remove != nil {
-			removeRows := toRows(remove)
-			if err := tx.Clauses(clause.Returning{}).Delete(&removeRows).Error; err != nil {
-				return fmt.Errorf("remove likes error: %w", err)
+		if removeIDs != nil {
+			var removeRows []database.Like
+			err := tx.
+				Clauses(clause.Returning{}).
+				Where("user_id = ? AND rule_id IN ?", userAuth.UserID, removeIDs).
+				Delete(&removeRows).Error
+			if err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+			if err := countLikes(removeRows, -1); err != nil {
+				return err
+			}
+			if err := webhook.Enqueue(tx, webhook.RuleUnliked, MapOf(removeRows, WebhookLikeOf)...); err != nil {
+				return err
 			}
 			update.Removed = fromRows(removeRows)
 		}
@@ -233,153 +584,852 @@ func (r *mutationResolver) Like(ctx context.Context, add []int, remove []int) (*
 	}); err != nil {
 		return nil, fmt.Errorf("database error: %w", err)
 	}
+	for _, id := range update.Added {
+		r.Publish(ctx, pubsub.RuleLikes(id.ID), pubsub.LikeEvent{RuleID: id.ID, UserID: userAuth.UserID, Liked: true})
+	}
+	for _, id := range update.Removed {
+		r.Publish(ctx, pubsub.RuleLikes(id.ID), pubsub.LikeEvent{RuleID: id.ID, UserID: userAuth.UserID, Liked: false})
+	}
 	return &update, nil
 }
 
-// Users is the resolver for the users field.
-func (r *queryResolver) Users(ctx context.Context, limit int, after int, name *string) (*model.UserPage, error) {
-	page := PageReader[database.User]{
-		Query: r.DB.WithContext(ctx),
-		After: database.User{ID: after},
-		Limit: limit,
-	}
-	if name != nil {
-		page.Query = page.Query.Scopes(database.User{Name: *name}.NameLike())
-	}
-	if err := page.Read(); err != nil {
-		return nil, fmt.Errorf("database error: %w", err)
-	}
-	return &model.UserPage{
-		Users: MapPointersOf(page.Rows, func(row database.User) model.User {
-			return model.User{
-				ID:   row.ID,
-				Name: row.Name,
-			}
-		}),
-		PageInfo: page.Info(),
-	}, nil
+// TagRule is the resolver for the tagRule field.
+func (r *mutationResolver) TagRule(ctx context.Context, id model.GlobalID, tags []string) (*model.Rule, error) {
+	return r.RetagRule(ctx, id, tags, nil)
 }
 
-// Rules is the resolver for the rules field.
-func (r *queryResolver) Rules(ctx context.Context, limit int, after int, userID *int) (*model.RulePage, error) {
-	page := PageReader[database.Rule]{
-		Query: r.DB.WithContext(ctx),
-		After: database.Rule{ID: after},
-		Limit: limit,
-	}
-	if userID != nil {
-		page.Query = page.Query.Where(&database.Rule{UserID: *userID})
-	}
-	if err := page.Read(); err != nil {
-		return nil, fmt.Errorf("database error: %w", err)
-	}
-	return &model.RulePage{
-		Rules: MapPointersOf(page.Rows, func(row database.Rule) model.Rule {
-			return model.Rule{
-				ID:      row.ID,
-				Summary: row.Summary,
-				Detail:  row.Detail,
-				Created: row.Created.String(),
-			}
-		}),
-		PageInfo: page.Info(),
-	}, nil
+// UntagRule is the resolver for the untagRule field.
+func (r *mutationResolver) UntagRule(ctx context.Context, id model.GlobalID, tags []string) (*model.Rule, error) {
+	return r.RetagRule(ctx, id, nil, tags)
 }
 
-// User is the resolver for the user field.
-func (r *ruleResolver) User(ctx context.Context, obj *model.Rule) (*model.User, error) {
-	row := database.Rule{
-		ID: obj.ID,
+// AddComment is the resolver for the addComment field.
+func (r *mutationResolver) AddComment(ctx context.Context, ruleID model.GlobalID, parentID *model.GlobalID, body string) (*model.Comment, error) {
+	userAuth := auth.ForContext(ctx)
+	if err := r.CheckEmailVerified(ctx, userAuth.UserID); err != nil {
+		return nil, err
 	}
-	if err := r.DB.WithContext(ctx).Preload("User").Find(&row).Error; err != nil {
-		return nil, fmt.Errorf("database error: %w", err)
+	if err := validate.NotBlank("body", body); err != nil {
+		return nil, err
 	}
-	return &model.User{
-		ID:   row.User.ID,
-		Name: row.User.Name,
-	}, nil
-}
-
-// Likes is the resolver for the likes field.
-func (r *ruleResolver) Likes(ctx context.Context, obj *model.Rule, limit int, after int) (*model.UserPage, error) {
-	page := PageReader[database.UserLike]{
-		Query: r.DB.WithContext(ctx).Preload("User").Where(database.UserLike{RuleID: obj.ID}),
-		After: database.UserLike{UserID: after},
-		Limit: limit,
+	row := database.Comment{
+		UserID:  userAuth.UserID,
+		Created: time.Now().Round(0), // Drop monotonic clock reading.
+		Body:    body,
 	}
-	if err := page.Read(); err != nil {
-		return nil, fmt.Errorf("database error: %w", err)
+	var err error
+	if row.RuleID, err = ruleID.Of("Rule"); err != nil {
+		return nil, err
 	}
-	return &model.UserPage{
-		Users: MapPointersOf(page.Rows, func(row database.UserLike) model.User {
-			return model.User{
-				ID:   row.User.ID,
-				Name: row.User.Name,
-			}
-		}),
-		PageInfo: page.Info(),
-	}, nil
-}
-
-// Rules is the resolver for the rules field.
-func (r *userResolver) Rules(ctx context.Context, obj *model.User, limit int, after int) (*model.RulePage, error) {
-	page := PageReader[database.Rule]{
-		Query: r.DB.WithContext(ctx).Where(database.Rule{UserID: obj.ID}),
-		After: database.Rule{ID: after},
-		Limit: limit,
+	if parentID != nil {
+		id, err := parentID.Of("Comment")
+		if err != nil {
+			return nil, err
+		}
+		row.ParentID = &id
 	}
-	if err := page.Read(); err != nil {
-		return nil, fmt.Errorf("database read error: %w", err)
-	}
-	return &model.RulePage{
-		Rules: MapPointersOf(page.Rows, func(row database.Rule) model.Rule {
-			return model.Rule{
-				ID:      row.ID,
-				Created: row.Created.String(),
-				Summary: row.Summary,
-				Detail:  row.Detail,
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&database.Rule{}).Where(&database.Rule{ID: row.RuleID}).Count(&count).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if count == 0 {
+			return apierror.NotFound("rule %s not found", ruleID)
+		}
+		if row.ParentID != nil {
+			var parent database.Comment
+			if err := tx.Where(&database.Comment{ID: *row.ParentID}).First(&parent).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return apierror.NotFound("comment %s not found", parentID)
+				}
+				return fmt.Errorf("database error: %w", err)
 			}
-		}),
-		PageInfo: nil,
-	}, nil
+			if parent.RuleID != row.RuleID {
+				return apierror.Invalid("comment %s is not on rule %s", parentID, ruleID)
+			}
+			if parent.Deleted != nil {
+				return apierror.New(apierror.CodeConflict, "comment %s is deleted and can't be replied to", parentID)
+			}
+		}
+		if err := tx.Create(&row).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	comment := CommentOf(row)
+	return &comment, nil
 }
 
-// Likes is the resolver for the likes field.
-func (r *userResolver) Likes(ctx context.Context, obj *model.User, limit int, after int) (*model.RulePage, error) {
-	page := PageReader[database.RuleLike]{
-		Query: r.DB.WithContext(ctx).Preload("Rule").Where(database.RuleLike{UserID: obj.ID}),
-		After: database.RuleLike{RuleID: after},
-		Limit: limit,
+// EditComment is the resolver for the editComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, id model.GlobalID, body string) (*model.Comment, error) {
+	userAuth := auth.ForContext(ctx)
+	commentID, err := id.Of("Comment")
+	if err != nil {
+		return nil, err
 	}
-	if err := page.Read(); err != nil {
-		return nil, fmt.Errorf("database error: %w", err)
+	if err := validate.NotBlank("body", body); err != nil {
+		return nil, err
 	}
-	return &model.RulePage{
-		Rules: MapPointersOf(page.Rows, func(row database.RuleLike) model.Rule {
-			return model.Rule{
-				ID:      row.Rule.ID,
-				Created: row.Rule.Created.String(),
-				Summary: row.Rule.Summary,
-				Detail:  row.Rule.Detail,
+	var row database.Comment
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&database.Comment{ID: commentID, UserID: userAuth.UserID}).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apierror.NotFound("comment %s not found", id)
 			}
-		}),
-		PageInfo: page.Info(),
-	}, nil
+			return fmt.Errorf("database error: %w", err)
+		}
+		if row.Deleted != nil {
+			return apierror.New(apierror.CodeConflict, "comment %s is deleted and can't be edited", id)
+		}
+		now := time.Now().Round(0) // Drop monotonic clock reading.
+		row.Body = body
+		row.Updated = &now
+		if err := tx.Model(&row).Select("body", "updated").Updates(&row).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	comment := CommentOf(row)
+	return &comment, nil
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id model.GlobalID) (*model.Comment, error) {
+	userAuth := auth.ForContext(ctx)
+	commentID, err := id.Of("Comment")
+	if err != nil {
+		return nil, err
+	}
+	cond := database.Comment{ID: commentID, UserID: userAuth.UserID}
+	// Moderators may delete anyone's comments.
+	if auth.HasRole(userAuth.Role, database.RoleModerator) {
+		cond.UserID = 0
+	}
+	var row database.Comment
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&cond).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apierror.NotFound("comment %s not found", id)
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		if row.Deleted != nil {
+			return nil
+		}
+		// The comment stays as a placeholder for its replies.
+		now := time.Now().Round(0) // Drop monotonic clock reading.
+		row.Deleted = &now
+		row.Body = ""
+		if err := tx.Model(&row).Select("deleted", "body").Updates(&row).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	comment := CommentOf(row)
+	return &comment, nil
+}
+
+// Follow is the resolver for the follow field.
+func (r *mutationResolver) Follow(ctx context.Context, userID model.GlobalID) (*model.User, error) {
+	return r.SetFollowing(ctx, userID, true)
+}
+
+// Unfollow is the resolver for the unfollow field.
+func (r *mutationResolver) Unfollow(ctx context.Context, userID model.GlobalID) (*model.User, error) {
+	return r.SetFollowing(ctx, userID, false)
+}
+
+// MarkNotificationsRead is the resolver for the markNotificationsRead field.
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, ids []*model.GlobalID) (int, error) {
+	userAuth := auth.ForContext(ctx)
+	qry := r.DB.WithContext(ctx).
+		Model(&database.Notification{}).
+		Where("user_id = ? AND read IS NULL", userAuth.UserID)
+	// Mark them all read if no IDs are given.
+	if ids != nil {
+		rowIDs, err := RowIDsOf(ids, "Notification")
+		if err != nil {
+			return 0, err
+		}
+		qry = qry.Where("id IN ?", rowIDs)
+	}
+	res := qry.Update("read", time.Now().Round(0)) // Drop monotonic clock reading.
+	if res.Error != nil {
+		return 0, fmt.Errorf("database error: %w", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
+
+// SetNotificationPreference is the resolver for the setNotificationPreference field.
+func (r *mutationResolver) SetNotificationPreference(ctx context.Context, kind model.NotificationKind, enabled bool) (*model.NotificationPreference, error) {
+	userAuth := auth.ForContext(ctx)
+	row := database.NotificationPreference{UserID: userAuth.UserID, Kind: string(kind), Enabled: enabled}
+	err := r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "kind"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled"}),
+	}).Create(&row).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &model.NotificationPreference{Kind: kind, Enabled: enabled}, nil
+}
+
+// CreateWebhook is the resolver for the createWebhook field.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, url string, secret string, events []model.WebhookEvent) (*model.Webhook, error) {
+	row := database.Webhook{
+		URL:     url,
+		Secret:  secret,
+		Events:  WebhookEventsOf(events),
+		Active:  true,
+		Created: time.Now().Round(0), // Drop monotonic clock reading.
+	}
+	if err := r.CheckWebhook(row); err != nil {
+		return nil, err
+	}
+	if err := r.DB.WithContext(ctx).Create(&row).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	hook := WebhookOf(row)
+	return &hook, nil
+}
+
+// UpdateWebhook is the resolver for the updateWebhook field.
+func (r *mutationResolver) UpdateWebhook(ctx context.Context, id model.GlobalID, url *string, secret *string, events []model.WebhookEvent, active *bool) (*model.Webhook, error) {
+	hookID, err := id.Of("Webhook")
+	if err != nil {
+		return nil, err
+	}
+	var row database.Webhook
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&database.Webhook{ID: hookID}).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apierror.NotFound("webhook %s not found", id)
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		if url != nil {
+			row.URL = *url
+		}
+		if secret != nil {
+			row.Secret = *secret
+		}
+		if events != nil {
+			row.Events = WebhookEventsOf(events)
+		}
+		if active != nil {
+			row.Active = *active
+		}
+		if err := r.CheckWebhook(row); err != nil {
+			return err
+		}
+		if err := tx.Model(&row).Select("url", "secret", "events", "active").Updates(&row).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	hook := WebhookOf(row)
+	return &hook, nil
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id model.GlobalID) (*model.GlobalID, error) {
+	hookID, err := id.Of("Webhook")
+	if err != nil {
+		return nil, err
+	}
+	res := r.DB.WithContext(ctx).Delete(&database.Webhook{ID: hookID})
+	if res.Error != nil {
+		return nil, fmt.Errorf("database error: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &id, nil
+}
+
+// SetUserRole is the resolver for the setUserRole field.
+func (r *mutationResolver) SetUserRole(ctx context.Context, userID model.GlobalID, role model.Role) (*model.User, error) {
+	userAuth := auth.ForContext(ctx)
+	if !auth.HasRole(userAuth.Role, string(role)) {
+		return nil, apierror.ErrForbidden
+	}
+	id, err := userID.Of("User")
+	if err != nil {
+		return nil, err
+	}
+	var user database.User
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&database.User{ID: id}).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apierror.NotFound("user %s not found", userID)
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		// Nobody may demote a user they couldn't have promoted.
+		if !auth.HasRole(userAuth.Role, user.Role) {
+			return apierror.ErrForbidden
+		}
+		user.Role = string(role)
+		if err := tx.Model(&user).Update("role", user.Role).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	out := UserOf(user)
+	return &out, nil
+}
+
+// ID is the resolver for the id field.
+func (r *notificationResolver) ID(ctx context.Context, obj *model.Notification) (*model.GlobalID, error) {
+	return &model.GlobalID{Type: "Notification", ID: obj.ID}, nil
+}
+
+// Rule is the resolver for the rule field.
+func (r *notificationResolver) Rule(ctx context.Context, obj *model.Notification) (*model.Rule, error) {
+	row, err := loader.For(ctx).RuleByID.Load(ctx, obj.RuleID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, apierror.NotFound("rule %d not found", obj.RuleID)
+	}
+	rule := RuleOf(*row)
+	return &rule, nil
+}
+
+// Actor is the resolver for the actor field.
+func (r *notificationResolver) Actor(ctx context.Context, obj *model.Notification) (*model.User, error) {
+	row, err := loader.For(ctx).UserByID.Load(ctx, obj.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, apierror.NotFound("user %d not found", obj.ActorID)
+	}
+	user := UserOf(*row)
+	return &user, nil
+}
+
+// Message is the resolver for the message field.
+func (r *notificationResolver) Message(ctx context.Context, obj *model.Notification) (string, error) {
+	loaders := loader.For(ctx)
+	ruleThunk := loaders.RuleByID.LoadThunk(ctx, obj.RuleID)
+	actorThunk := loaders.UserByID.LoadThunk(ctx, obj.ActorID)
+	rule, err := ruleThunk()
+	if err != nil {
+		return "", err
+	}
+	if rule == nil {
+		return "", apierror.NotFound("rule %d not found", obj.RuleID)
+	}
+	actor, err := actorThunk()
+	if err != nil {
+		return "", err
+	}
+	if actor == nil {
+		return "", apierror.NotFound("user %d not found", obj.ActorID)
+	}
+	return NotificationMessage(obj, *rule, *actor), nil
+}
+
+// Node is the resolver for the node field.
+func (r *queryResolver) Node(ctx context.Context, id model.GlobalID) (model.Node, error) {
+	return LoadNode(ctx, id)()
+}
+
+// Nodes is the resolver for the nodes field.
+func (r *queryResolver) Nodes(ctx context.Context, ids []*model.GlobalID) ([]model.Node, error) {
+	// Queue every node before waiting on any so that they load in batches.
+	thunks := MapOf(ids, func(id *model.GlobalID) func() (model.Node, error) {
+		return LoadNode(ctx, *id)
+	})
+	return MapOfError(thunks, func(thunk func() (model.Node, error)) (model.Node, error) {
+		return thunk()
+	})
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context, first *int, after *string, last *int, before *string, name *string) (*model.UserConnection, error) {
+	page := PageReader[database.User]{
+		Query:  r.DB.WithContext(ctx),
+		First:  first,
+		After:  after,
+		Last:   last,
+		Before: before,
+	}
+	if name != nil {
+		page.Query = page.Query.Scopes(database.User{Name: *name}.NameLike())
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return UserConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// Rules is the resolver for the rules field.
+func (r *queryResolver) Rules(ctx context.Context, first *int, after *string, last *int, before *string, filter *model.RuleFilter, orderBy *model.RuleOrder) (*model.RuleConnection, error) {
+	scope, err := RuleFilterOf(filter)
+	if err != nil {
+		return nil, err
+	}
+	order := model.RuleOrderOldest
+	if orderBy != nil {
+		order = *orderBy
+	}
+	page := PageReader[database.Rule]{
+		Query:  r.DB.WithContext(ctx).Scopes(scope),
+		First:  first,
+		After:  after,
+		Last:   last,
+		Before: before,
+	}
+	if order == model.RuleOrderTrending {
+		page.OrderAt = func(now time.Time) []SortKey { return RuleOrderOf(order, now) }
+	} else {
+		page.Order = RuleOrderOf(order, time.Now())
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return RuleConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// SearchRules is the resolver for the searchRules field.
+func (r *queryResolver) SearchRules(ctx context.Context, query string, first *int, after *string) (*model.RuleSearchConnection, error) {
+	search, err := RuleSearchOf(r.DB, query)
+	if err != nil {
+		return nil, err
+	}
+	page := PageReader[database.Rule]{
+		Query: r.DB.WithContext(ctx).Scopes(search.Scope),
+		Order: search.Order(),
+		First: first,
+		After: after,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	highlights, err := search.Highlights(r.DB.WithContext(ctx), page.Rows)
+	if err != nil {
+		return nil, err
+	}
+	return &model.RuleSearchConnection{
+		Edges: MapPointersOf(page.Rows, func(row database.Rule) model.RuleSearchEdge {
+			node := RuleOf(row)
+			rank, _ := page.Value(row, "rank").(float64)
+			highlight := highlights[row.ID]
+			return model.RuleSearchEdge{
+				Cursor:  page.Cursor(row),
+				Node:    &node,
+				Rank:    rank,
+				Summary: highlight.Summary,
+				Detail:  highlight.Detail,
+			}
+		}),
+		PageInfo: page.Info(),
+	}, nil
 }
 
+// Tags is the resolver for the tags field.
+func (r *queryResolver) Tags(ctx context.Context, first *int, after *string) (*model.TagConnection, error) {
+	page := PageReader[database.Tag]{
+		Query: r.DB.WithContext(ctx),
+		Order: []SortKey{{
+			Column: "rule_count",
+			Expr:   clause.Expr{SQL: "(SELECT COUNT(*) FROM rule_tags WHERE rule_tags.tag_id = tags.id)"},
+			Desc:   true,
+		}, {Column: "name"}},
+		First: first,
+		After: after,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return TagConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// Feed is the resolver for the feed field.
+func (r *queryResolver) Feed(ctx context.Context, first *int, after *string, last *int, before *string) (*model.RuleConnection, error) {
+	userAuth := auth.ForContext(ctx)
+	page := PageReader[database.Rule]{
+		Query:  r.DB.WithContext(ctx).Where("rules.user_id IN (SELECT followee_id FROM follows WHERE follower_id = ?)", userAuth.UserID),
+		Order:  RuleOrderOf(model.RuleOrderNewest, time.Now()),
+		First:  first,
+		After:  after,
+		Last:   last,
+		Before: before,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return RuleConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, unreadOnly *bool, first *int, after *string) (*model.NotificationConnection, error) {
+	userAuth := auth.ForContext(ctx)
+	qry := r.DB.WithContext(ctx).Where(&database.Notification{UserID: userAuth.UserID})
+	if unreadOnly != nil && *unreadOnly {
+		qry = qry.Where("read IS NULL")
+	}
+	page := PageReader[database.Notification]{
+		Query: qry,
+		Order: []SortKey{{Column: "updated", Desc: true}, {Column: "id", Desc: true}},
+		First: first,
+		After: after,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return NotificationConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// NotificationPreferences is the resolver for the notificationPreferences field.
+func (r *queryResolver) NotificationPreferences(ctx context.Context) ([]*model.NotificationPreference, error) {
+	userAuth := auth.ForContext(ctx)
+	var rows []database.NotificationPreference
+	if err := r.DB.WithContext(ctx).Where(&database.NotificationPreference{UserID: userAuth.UserID}).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	enabled := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		enabled[row.Kind] = row.Enabled
+	}
+	return MapPointersOf(model.AllNotificationKind, func(kind model.NotificationKind) model.NotificationPreference {
+		on, ok := enabled[string(kind)]
+		return model.NotificationPreference{Kind: kind, Enabled: on || !ok}
+	}), nil
+}
+
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	var rows []database.Webhook
+	if err := r.DB.WithContext(ctx).Order("id").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return MapPointersOf(rows, WebhookOf), nil
+}
+
+// ID is the resolver for the id field.
+func (r *ruleResolver) ID(ctx context.Context, obj *model.Rule) (*model.GlobalID, error) {
+	return &model.GlobalID{Type: "Rule", ID: obj.ID}, nil
+}
+
+// User is the resolver for the user field.
+func (r *ruleResolver) User(ctx context.Context, obj *model.Rule) (*model.User, error) {
+	row, err := loader.For(ctx).UserByID.Load(ctx, obj.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, apierror.NotFound("user %d not found", obj.UserID)
+	}
+	user := UserOf(*row)
+	return &user, nil
+}
+
+// Decider is the resolver for the decider field.
+func (r *ruleResolver) Decider(ctx context.Context, obj *model.Rule) (*model.User, error) {
+	if obj.DeciderID == nil {
+		return nil, nil
+	}
+	row, err := loader.For(ctx).UserByID.Load(ctx, *obj.DeciderID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, apierror.NotFound("user %d not found", *obj.DeciderID)
+	}
+	user := UserOf(*row)
+	return &user, nil
+}
+
+// Likes is the resolver for the likes field.
+func (r *ruleResolver) Likes(ctx context.Context, obj *model.Rule, first *int, after *string) (*model.UserConnection, error) {
+	afterID, limit, err := PageArgs(first, after)
+	if err != nil {
+		return nil, err
+	}
+	page, err := loader.For(ctx).RuleLikes.Load(ctx, loader.PageKey{ID: obj.ID, After: afterID, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	cursor := func(row database.User) string { return IDCursor(row.ID) }
+	return UserConnectionOf(page.Rows, cursor, PageInfoOf(page, cursor)), nil
+}
+
+// ViewerHasLiked is the resolver for the viewerHasLiked field.
+func (r *ruleResolver) ViewerHasLiked(ctx context.Context, obj *model.Rule) (bool, error) {
+	userAuth := auth.ForContext(ctx)
+	if userAuth == nil {
+		return false, nil
+	}
+	return loader.For(ctx).Liked.Load(ctx, loader.LikeKey{UserID: userAuth.UserID, RuleID: obj.ID})
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *ruleResolver) Revisions(ctx context.Context, obj *model.Rule) ([]*model.RuleRevision, error) {
+	var rows []database.RuleRevision
+	if err := r.DB.WithContext(ctx).Where(&database.RuleRevision{RuleID: obj.ID}).Order("number").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return MapPointersOf(rows, RuleRevisionOf), nil
+}
+
+// Diff is the resolver for the diff field.
+func (r *ruleResolver) Diff(ctx context.Context, obj *model.Rule, from int, to int) (*model.RevisionDiff, error) {
+	var rows []database.RuleRevision
+	err := r.DB.WithContext(ctx).
+		Where("rule_id = ? AND number IN ?", obj.ID, []int{from, to}).
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	revisions := make(map[int]database.RuleRevision)
+	for _, row := range rows {
+		revisions[row.Number] = row
+	}
+	a, ok := revisions[from]
+	if !ok {
+		return nil, apierror.NotFound("revision %d not found", from)
+	}
+	b, ok := revisions[to]
+	if !ok {
+		return nil, apierror.NotFound("revision %d not found", to)
+	}
+	detailOf := func(row database.RuleRevision) string {
+		if row.Detail == nil {
+			return ""
+		}
+		return *row.Detail
+	}
+	return &model.RevisionDiff{
+		From:    from,
+		To:      to,
+		Summary: DiffChunksOf(diff.Words(a.Summary, b.Summary)),
+		Detail:  DiffChunksOf(diff.Lines(detailOf(a), detailOf(b))),
+	}, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *ruleResolver) Comments(ctx context.Context, obj *model.Rule, first *int, after *string) (*model.CommentConnection, error) {
+	page := PageReader[database.Comment]{
+		Query: r.DB.WithContext(ctx).Where(&database.Comment{RuleID: obj.ID}).Where("parent_id IS NULL"),
+		First: first,
+		After: after,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return CommentConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// Tags is the resolver for the tags field.
+func (r *ruleResolver) Tags(ctx context.Context, obj *model.Rule) ([]*model.Tag, error) {
+	rows, err := loader.For(ctx).RuleTags.Load(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	return MapPointersOf(rows, TagOf), nil
+}
+
+// Editor is the resolver for the editor field.
+func (r *ruleRevisionResolver) Editor(ctx context.Context, obj *model.RuleRevision) (*model.User, error) {
+	row, err := loader.For(ctx).UserByID.Load(ctx, obj.EditorID)
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, apierror.NotFound("user %d not found", obj.EditorID)
+	}
+	user := UserOf(*row)
+	return &user, nil
+}
+
+// RuleCreated is the resolver for the ruleCreated field.
+func (r *subscriptionResolver) RuleCreated(ctx context.Context) (<-chan *model.Rule, error) {
+	return Subscribe(ctx, r.Resolver, pubsub.RuleCreated, func(event pubsub.RuleEvent) (*model.Rule, bool, error) {
+		var row database.Rule
+		if err := r.DB.WithContext(ctx).Where(&database.Rule{ID: event.RuleID}).Find(&row).Error; err != nil {
+			return nil, false, fmt.Errorf("database error: %w", err)
+		}
+		if row.ID == 0 {
+			// Deleted before it could be delivered.
+			return nil, false, nil
+		}
+		rule := RuleOf(row)
+		return &rule, true, nil
+	}), nil
+}
+
+// RuleDeleted is the resolver for the ruleDeleted field.
+func (r *subscriptionResolver) RuleDeleted(ctx context.Context) (<-chan *model.GlobalID, error) {
+	return Subscribe(ctx, r.Resolver, pubsub.RuleDeleted, func(event pubsub.RuleEvent) (*model.GlobalID, bool, error) {
+		return &model.GlobalID{Type: "Rule", ID: event.RuleID}, true, nil
+	}), nil
+}
+
+// LikesChanged is the resolver for the likesChanged field.
+func (r *subscriptionResolver) LikesChanged(ctx context.Context, ruleID model.GlobalID) (<-chan *model.LikesChange, error) {
+	id, err := ruleID.Of("Rule")
+	if err != nil {
+		return nil, err
+	}
+	return Subscribe(ctx, r.Resolver, pubsub.RuleLikes(id), func(event pubsub.LikeEvent) (*model.LikesChange, bool, error) {
+		return &model.LikesChange{
+			RuleID: model.GlobalID{Type: "Rule", ID: event.RuleID},
+			UserID: event.UserID,
+			Liked:  event.Liked,
+		}, true, nil
+	}), nil
+}
+
+// RuleCount is the resolver for the ruleCount field.
+func (r *tagResolver) RuleCount(ctx context.Context, obj *model.Tag) (int, error) {
+	return loader.For(ctx).TagRules.Load(ctx, obj.ID)
+}
+
+// ID is the resolver for the id field.
+func (r *userResolver) ID(ctx context.Context, obj *model.User) (*model.GlobalID, error) {
+	return &model.GlobalID{Type: "User", ID: obj.ID}, nil
+}
+
+// Rules is the resolver for the rules field.
+func (r *userResolver) Rules(ctx context.Context, obj *model.User, first *int, after *string, last *int, before *string) (*model.RuleConnection, error) {
+	page := PageReader[database.Rule]{
+		Query:  r.DB.WithContext(ctx).Where(database.Rule{UserID: obj.ID}),
+		First:  first,
+		After:  after,
+		Last:   last,
+		Before: before,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return RuleConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// Likes is the resolver for the likes field.
+func (r *userResolver) Likes(ctx context.Context, obj *model.User, first *int, after *string) (*model.RuleConnection, error) {
+	afterID, limit, err := PageArgs(first, after)
+	if err != nil {
+		return nil, err
+	}
+	page, err := loader.For(ctx).UserLikes.Load(ctx, loader.PageKey{ID: obj.ID, After: afterID, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	cursor := func(row database.Rule) string { return IDCursor(row.ID) }
+	return RuleConnectionOf(page.Rows, cursor, PageInfoOf(page, cursor)), nil
+}
+
+// Followers is the resolver for the followers field.
+func (r *userResolver) Followers(ctx context.Context, obj *model.User, first *int, after *string) (*model.UserConnection, error) {
+	page := PageReader[database.User]{
+		Query: r.DB.WithContext(ctx).Where("users.id IN (SELECT follower_id FROM follows WHERE followee_id = ?)", obj.ID),
+		First: first,
+		After: after,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return UserConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// Following is the resolver for the following field.
+func (r *userResolver) Following(ctx context.Context, obj *model.User, first *int, after *string) (*model.UserConnection, error) {
+	page := PageReader[database.User]{
+		Query: r.DB.WithContext(ctx).Where("users.id IN (SELECT followee_id FROM follows WHERE follower_id = ?)", obj.ID),
+		First: first,
+		After: after,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return UserConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// ID is the resolver for the id field.
+func (r *webhookResolver) ID(ctx context.Context, obj *model.Webhook) (*model.GlobalID, error) {
+	return &model.GlobalID{Type: "Webhook", ID: obj.ID}, nil
+}
+
+// Deliveries is the resolver for the deliveries field.
+func (r *webhookResolver) Deliveries(ctx context.Context, obj *model.Webhook, first *int, after *string) (*model.WebhookDeliveryConnection, error) {
+	page := PageReader[database.WebhookDelivery]{
+		Query: r.DB.WithContext(ctx).Where(&database.WebhookDelivery{WebhookID: obj.ID}),
+		Order: []SortKey{{Column: "id", Desc: true}},
+		First: first,
+		After: after,
+	}
+	if err := page.Read(); err != nil {
+		return nil, err
+	}
+	return WebhookDeliveryConnectionOf(page.Rows, page.Cursor, page.Info()), nil
+}
+
+// ID is the resolver for the id field.
+func (r *webhookDeliveryResolver) ID(ctx context.Context, obj *model.WebhookDelivery) (*model.GlobalID, error) {
+	return &model.GlobalID{Type: "WebhookDelivery", ID: obj.ID}, nil
+}
+
+// Comment returns generated.CommentResolver implementation.
+func (r *Resolver) Comment() generated.CommentResolver { return &commentResolver{r} }
+
+// LikesChange returns generated.LikesChangeResolver implementation.
+func (r *Resolver) LikesChange() generated.LikesChangeResolver { return &likesChangeResolver{r} }
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
+// Notification returns generated.NotificationResolver implementation.
+func (r *Resolver) Notification() generated.NotificationResolver { return &notificationResolver{r} }
+
 // Query returns generated.QueryResolver implementation.
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
 // Rule returns generated.RuleResolver implementation.
 func (r *Resolver) Rule() generated.RuleResolver { return &ruleResolver{r} }
 
+// RuleRevision returns generated.RuleRevisionResolver implementation.
+func (r *Resolver) RuleRevision() generated.RuleRevisionResolver { return &ruleRevisionResolver{r} }
+
+// Subscription returns generated.SubscriptionResolver implementation.
+func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
+
+// Tag returns generated.TagResolver implementation.
+func (r *Resolver) Tag() generated.TagResolver { return &tagResolver{r} }
+
 // User returns generated.UserResolver implementation.
 func (r *Resolver) User() generated.UserResolver { return &userResolver{r} }
 
+// Webhook returns generated.WebhookResolver implementation.
+func (r *Resolver) Webhook() generated.WebhookResolver { return &webhookResolver{r} }
+
+// WebhookDelivery returns generated.WebhookDeliveryResolver implementation.
+func (r *Resolver) WebhookDelivery() generated.WebhookDeliveryResolver {
+	return &webhookDeliveryResolver{r}
+}
+
+type commentResolver struct{ *Resolver }
+type likesChangeResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type notificationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type ruleResolver struct{ *Resolver }
+type ruleRevisionResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type tagResolver struct{ *Resolver }
 type userResolver struct{ *Resolver }
+type webhookResolver struct{ *Resolver }
+type webhookDeliveryResolver struct{ *Resolver }
@@ -0,0 +1,184 @@
+package graph
+
+import (
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// SearchExcerptWords is the most words of a rule's detail shown around the
+// words matching a search, as for ts_headline.
+const SearchExcerptWords = 35
+
+// RuleSearch finds the rules matching a search query. Postgres matches the
+// query against the rules' full-text search column, while other databases,
+// such as SQLite in development, match each word of the query with LIKE.
+type RuleSearch struct {
+	query    string
+	terms    []string
+	fullText bool
+	match    *regexp.Regexp
+}
+
+func RuleSearchOf(db *gorm.DB, query string) (*RuleSearch, error) {
+	var terms, patterns []string
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.TrimFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if word != "" {
+			terms = append(terms, word)
+			patterns = append(patterns, regexp.QuoteMeta(word))
+		}
+	}
+	if len(terms) == 0 {
+		return nil, apierror.Invalid("search query has no words")
+	}
+	return &RuleSearch{
+		query:    query,
+		terms:    terms,
+		fullText: db.Dialector.Name() == "postgres",
+		match:    regexp.MustCompile("(?i)" + strings.Join(patterns, "|")),
+	}, nil
+}
+
+func (s *RuleSearch) tsquery() clause.Expr {
+	return clause.Expr{SQL: "websearch_to_tsquery('english', ?)", Vars: []interface{}{s.query}}
+}
+
+// Scope selects the rules matching the search.
+func (s *RuleSearch) Scope(db *gorm.DB) *gorm.DB {
+	if s.fullText {
+		return db.Where("rules.search @@ ?", s.tsquery())
+	}
+	for _, term := range s.terms {
+		pattern := "%" + likeEscaper.Replace(term) + "%"
+		db = db.Where(`(LOWER(rules.summary) LIKE ? ESCAPE '\' OR LOWER(rules.detail) LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+	return db
+}
+
+// Order returns the keys to sort rules by, best match first.
+func (s *RuleSearch) Order() []SortKey {
+	var rank clause.Expr
+	if s.fullText {
+		rank = clause.Expr{SQL: "ts_rank(rules.search, ?)::float8", Vars: []interface{}{s.tsquery()}}
+	} else {
+		// Words in the summary weigh as much more than words in the detail as
+		// they do in the full-text search column.
+		var sql []string
+		for _, term := range s.terms {
+			pattern := "%" + likeEscaper.Replace(term) + "%"
+			sql = append(sql, `CASE WHEN LOWER(rules.summary) LIKE ? ESCAPE '\' THEN 1.0 ELSE 0.0 END`,
+				`CASE WHEN LOWER(rules.detail) LIKE ? ESCAPE '\' THEN 0.4 ELSE 0.0 END`)
+			rank.Vars = append(rank.Vars, pattern, pattern)
+		}
+		rank.SQL = "(" + strings.Join(sql, " + ") + ")"
+	}
+	return []SortKey{{Column: "rank", Expr: rank, Desc: true}, {Column: "id", Desc: true}}
+}
+
+// RuleHighlight is the text of a rule as HTML, with the words matching a
+// search wrapped in <b></b>. Its detail is cut down to an excerpt around them.
+type RuleHighlight struct {
+	ID      int
+	Summary string
+	Detail  *string
+}
+
+// Highlights returns the highlighted text of rules by ID.
+func (s *RuleSearch) Highlights(db *gorm.DB, rules []database.Rule) (map[int]RuleHighlight, error) {
+	highlights := make(map[int]RuleHighlight, len(rules))
+	if len(rules) == 0 {
+		return highlights, nil
+	}
+	if s.fullText {
+		var rows []RuleHighlight
+		q := s.tsquery()
+		sel := fmt.Sprintf(`StartSel="%s", StopSel="%s"`, headlineStart, headlineStop)
+		if err := db.Model(&database.Rule{}).
+			Select("id, ts_headline('english', summary, ?, ?) AS summary, ts_headline('english', detail, ?, ?) AS detail",
+				q, sel+", HighlightAll=true", q, fmt.Sprintf("%s, MaxWords=%d", sel, SearchExcerptWords)).
+			Where("id IN ?", MapOf(rules, func(row database.Rule) int { return row.ID })).
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		for _, row := range rows {
+			row.Summary = headlineHTML(row.Summary)
+			if row.Detail != nil {
+				detail := headlineHTML(*row.Detail)
+				row.Detail = &detail
+			}
+			highlights[row.ID] = row
+		}
+		return highlights, nil
+	}
+	for _, row := range rules {
+		highlight := RuleHighlight{ID: row.ID, Summary: s.highlight(row.Summary)}
+		if row.Detail != nil {
+			detail := s.highlight(s.excerpt(*row.Detail))
+			highlight.Detail = &detail
+		}
+		highlights[row.ID] = highlight
+	}
+	return highlights, nil
+}
+
+// highlight escapes text as HTML, wrapping the words matching the search in
+// <b></b>.
+func (s *RuleSearch) highlight(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range s.match.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:loc[0]]))
+		b.WriteString("<b>")
+		b.WriteString(html.EscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</b>")
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// ts_headline marks matches with these rather than <b></b> so that the text
+// can be escaped as HTML before they're swapped for tags.
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+var headlineTags = strings.NewReplacer(headlineStart, "<b>", headlineStop, "</b>")
+
+// headlineHTML escapes a ts_headline as HTML, turning its marks into <b></b>.
+func headlineHTML(text string) string {
+	return headlineTags.Replace(html.EscapeString(text))
+}
+
+// excerpt returns at most SearchExcerptWords words of text, starting a little
+// before the first word matching the search.
+func (s *RuleSearch) excerpt(text string) string {
+	words := strings.Fields(text)
+	if len(words) <= SearchExcerptWords {
+		return text
+	}
+	start := 0
+	for i, word := range words {
+		if s.match.MatchString(word) {
+			start = i - SearchExcerptWords/4
+			break
+		}
+	}
+	if start > len(words)-SearchExcerptWords {
+		start = len(words) - SearchExcerptWords
+	}
+	if start < 0 {
+		start = 0
+	}
+	return strings.Join(words[start:start+SearchExcerptWords], " ")
+}
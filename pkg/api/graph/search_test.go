@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/client"
+	"testing"
+)
+
+func TestSearchRules(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	var resp map[string]interface{}
+	s.post(`mutation { createRule(summary: "no <script>alert(1)</script> tabs & spaces", detail: "indent with <i>tabs</i>") { id } }`, &resp, alice)
+	s.createRule(alice, "unrelated")
+
+	var search struct {
+		SearchRules struct {
+			Edges []struct {
+				Node    struct{ Summary string }
+				Summary string
+				Detail  *string
+			}
+		}
+	}
+	s.post(`query($query: String!) { searchRules(query: $query) { edges { node { summary } summary detail } } }`, &search, client.Var("query", "tabs"))
+	edges := search.SearchRules.Edges
+	if len(edges) != 1 {
+		t.Fatalf("expected 1 result, got %+v", edges)
+	}
+	// Highlights are HTML, so the rule's own text must be escaped.
+	if want := "no &lt;script&gt;alert(1)&lt;/script&gt; <b>tabs</b> &amp; spaces"; edges[0].Summary != want {
+		t.Errorf("expected summary %q, got %q", want, edges[0].Summary)
+	}
+	if want := "indent with &lt;i&gt;<b>tabs</b>&lt;/i&gt;"; edges[0].Detail == nil || *edges[0].Detail != want {
+		t.Errorf("expected detail %q, got %v", want, edges[0].Detail)
+	}
+}
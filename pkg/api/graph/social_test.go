@@ -0,0 +1,171 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/client"
+	"reflect"
+	"testing"
+)
+
+func TestComments(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	bob := s.user("bob")
+	rule := s.createRule(alice, "rule")
+
+	var add struct{ AddComment struct{ ID string } }
+	query := `mutation($rule: ID!, $parent: ID, $body: String!) { addComment(ruleId: $rule, parentId: $parent, body: $body) { id } }`
+	s.post(query, &add, alice, client.Var("rule", rule), client.Var("body", "first"))
+	parent := add.AddComment.ID
+	s.post(query, &add, bob, client.Var("rule", rule), client.Var("parent", parent), client.Var("body", "reply"))
+
+	var resp map[string]interface{}
+	// Only the author may edit a comment, and others can't tell it from one
+	// that doesn't exist.
+	err := s.Post(`mutation($id: ID!) { editComment(id: $id, body: "edited") { id } }`, &resp, bob, client.Var("id", parent))
+	expectCode(t, err, "NOT_FOUND")
+	s.post(`mutation($id: ID!) { deleteComment(id: $id) { id } }`, &resp, alice, client.Var("id", parent))
+
+	var comments struct {
+		Node struct {
+			Comments struct {
+				Edges []struct {
+					Node struct {
+						Body    *string
+						Deleted *string
+						Replies struct {
+							Edges []struct{ Node struct{ Body *string } }
+						}
+					}
+				}
+			}
+		}
+	}
+	s.post(`query($id: ID!) { node(id: $id) { ... on Rule {
+		comments { edges { node { body deleted replies { edges { node { body } } } } } }
+	} } }`, &comments, client.Var("id", rule))
+	edges := comments.Node.Comments.Edges
+	if len(edges) != 1 {
+		t.Fatalf("expected 1 top-level comment, got %+v", edges)
+	}
+	// Deleted comments keep their place in the thread but lose their body.
+	if edges[0].Node.Body != nil || edges[0].Node.Deleted == nil {
+		t.Errorf("expected the comment to be deleted, got %+v", edges[0].Node)
+	}
+	if replies := edges[0].Node.Replies.Edges; len(replies) != 1 || replies[0].Node.Body == nil || *replies[0].Node.Body != "reply" {
+		t.Errorf("expected the reply to remain, got %+v", replies)
+	}
+}
+
+func TestTags(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	first := s.createRule(alice, "first")
+	second := s.createRule(alice, "second")
+
+	var tag struct {
+		TagRule struct{ Tags []struct{ Name string } }
+	}
+	query := `mutation($id: ID!, $tags: [String!]!) { tagRule(id: $id, tags: $tags) { tags { name } } }`
+	s.post(query, &tag, alice, client.Var("id", first), client.Var("tags", []string{"Food", "drink"}))
+	if len(tag.TagRule.Tags) != 2 {
+		t.Errorf("expected 2 tags, got %+v", tag.TagRule.Tags)
+	}
+	s.post(query, &tag, alice, client.Var("id", second), client.Var("tags", []string{"food"}))
+
+	type tagCount struct {
+		Name      string
+		RuleCount int
+	}
+	var tags struct {
+		Tags struct {
+			Edges []struct{ Node tagCount }
+		}
+	}
+	s.post(`{ tags { edges { node { name ruleCount } } } }`, &tags)
+	want := []tagCount{{"food", 2}, {"drink", 1}}
+	got := make([]tagCount, len(tags.Tags.Edges))
+	for i, edge := range tags.Tags.Edges {
+		got[i] = edge.Node
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected tags %v, got %v", want, got)
+	}
+
+	all := s.rules(client.Var("filter", map[string]interface{}{"tags": []string{"food", "drink"}, "tagMatch": "ALL"}))
+	if !reflect.DeepEqual(all.summaries(), []string{"first"}) {
+		t.Errorf("expected rules with all tags, got %v", all.summaries())
+	}
+	anyTag := s.rules(client.Var("filter", map[string]interface{}{"tags": []string{"food", "drink"}}))
+	if !reflect.DeepEqual(anyTag.summaries(), []string{"first", "second"}) {
+		t.Errorf("expected rules with any tag, got %v", anyTag.summaries())
+	}
+}
+
+func TestFollowFeed(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	bobID := s.signup("bob")
+	bobToken, _ := s.login("bob")
+	bob := bearer(bobToken)
+	s.user("carol")
+
+	var resp map[string]interface{}
+	s.post(`mutation($id: ID!) { follow(userId: $id) { id } }`, &resp, alice, client.Var("id", bobID))
+	s.createRule(bob, "followed")
+
+	var feed struct{ Feed ruleConnection }
+	feedQuery := `{ feed { edges { node { summary } } } }`
+	s.post(feedQuery, &feed, alice)
+	if !reflect.DeepEqual(feed.Feed.summaries(), []string{"followed"}) {
+		t.Errorf("expected the followed user's rules, got %v", feed.Feed.summaries())
+	}
+
+	s.post(`mutation($id: ID!) { unfollow(userId: $id) { id } }`, &resp, alice, client.Var("id", bobID))
+	s.post(feedQuery, &feed, alice)
+	if len(feed.Feed.Edges) != 0 {
+		t.Errorf("expected an empty feed, got %v", feed.Feed.summaries())
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	bob := s.user("bob")
+	carol := s.user("carol")
+	rule := s.createRule(alice, "rule")
+
+	var resp map[string]interface{}
+	like := `mutation($ids: [ID!]) { like(add: $ids) { added } }`
+	s.post(like, &resp, bob, client.Var("ids", []string{rule}))
+	s.post(like, &resp, carol, client.Var("ids", []string{rule}))
+
+	var notifications struct {
+		Notifications struct {
+			Edges []struct {
+				Node struct {
+					ID    string
+					Kind  string
+					Count int
+					Read  *string
+				}
+			}
+		}
+	}
+	query := `query($unread: Boolean) { notifications(unreadOnly: $unread) { edges { node { id kind count read } } } }`
+	s.post(query, &notifications, alice)
+	edges := notifications.Notifications.Edges
+	// Likes of the same rule are grouped into one notification.
+	if len(edges) != 1 || edges[0].Node.Kind != "RULE_LIKED" || edges[0].Node.Count != 2 {
+		t.Fatalf("expected one notification of 2 likes, got %+v", edges)
+	}
+
+	var read struct{ MarkNotificationsRead int }
+	s.post(`mutation { markNotificationsRead }`, &read, alice)
+	if read.MarkNotificationsRead != 1 {
+		t.Errorf("expected 1 notification marked read, got %d", read.MarkNotificationsRead)
+	}
+	s.post(query, &notifications, alice, client.Var("unread", true))
+	if len(notifications.Notifications.Edges) != 0 {
+		t.Errorf("expected no unread notifications, got %+v", notifications.Notifications.Edges)
+	}
+}
@@ -0,0 +1,103 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/client"
+	"testing"
+	"time"
+)
+
+// testSubscribeWait is how long a subscription is given to start before
+// events it should see are published.
+const testSubscribeWait = time.Millisecond * 50
+
+func TestSubscriptions(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	bob := s.user("bob")
+
+	created := s.Websocket(`subscription { ruleCreated { summary user { name } } }`)
+	defer created.Close()
+	deleted := s.Websocket(`subscription { ruleDeleted }`)
+	defer deleted.Close()
+	time.Sleep(testSubscribeWait)
+	id := s.createRule(alice, "rule")
+	var rule struct {
+		RuleCreated struct {
+			Summary string
+			User    struct{ Name string }
+		}
+	}
+	if err := created.Next(&rule); err != nil {
+		t.Fatal(err)
+	}
+	if rule.RuleCreated.Summary != "rule" || rule.RuleCreated.User.Name != "alice" {
+		t.Errorf("expected alice's rule, got %+v", rule.RuleCreated)
+	}
+
+	likes := s.Websocket(`subscription($id: ID!) { likesChanged(ruleId: $id) { ruleId liked user { name } } }`, client.Var("id", id))
+	defer likes.Close()
+	time.Sleep(testSubscribeWait)
+	var resp map[string]interface{}
+	query := `mutation($add: [ID!], $remove: [ID!]) { like(add: $add, remove: $remove) { added } }`
+	var change struct {
+		LikesChanged struct {
+			RuleID string
+			Liked  bool
+			User   struct{ Name string }
+		}
+	}
+	for _, liked := range []bool{true, false} {
+		if liked {
+			s.post(query, &resp, bob, client.Var("add", []string{id}))
+		} else {
+			s.post(query, &resp, bob, client.Var("remove", []string{id}))
+		}
+		if err := likes.Next(&change); err != nil {
+			t.Fatal(err)
+		}
+		if got := change.LikesChanged; got.RuleID != id || got.Liked != liked || got.User.Name != "bob" {
+			t.Errorf("expected bob's like of %s to be %v, got %+v", id, liked, got)
+		}
+	}
+
+	s.post(`mutation($id: ID!) { deleteRule(id: $id) }`, &resp, alice, client.Var("id", id))
+	var deletion struct{ RuleDeleted string }
+	if err := deleted.Next(&deletion); err != nil {
+		t.Fatal(err)
+	}
+	if deletion.RuleDeleted != id {
+		t.Errorf("expected rule %s deleted, got %s", id, deletion.RuleDeleted)
+	}
+}
+
+func TestSubscriptionEndsWithSession(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.user("alice")
+	s.signup("bob")
+	token, _ := s.login("bob")
+
+	sub := s.WebsocketWithPayload(`subscription { ruleCreated { summary } }`, map[string]interface{}{
+		"Authorization": "Bearer " + token,
+	})
+	defer sub.Close()
+	// Give the subscription time to start before publishing.
+	time.Sleep(testSubscribeWait)
+	s.createRule(alice, "first")
+	var event struct {
+		RuleCreated struct{ Summary string }
+	}
+	if err := sub.Next(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.RuleCreated.Summary != "first" {
+		t.Errorf("expected the first rule, got %+v", event)
+	}
+
+	var resp map[string]interface{}
+	s.post(`mutation { logout }`, &resp, bearer(token))
+	time.Sleep(testSessionCheckInterval * 4)
+	s.createRule(alice, "second")
+	if err := sub.Next(&event); err == nil {
+		t.Errorf("expected the subscription to end with its session, got %+v", event)
+	}
+}
@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"regexp"
+	"strings"
+)
+
+const (
+	MaxTagLength = 32
+	MaxRuleTags  = 10
+)
+
+var tagName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// TagNamesOf returns tag names in lower case without duplicates, or an error
+// if any isn't words of letters and digits joined by hyphens.
+func TagNamesOf(names []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(names))
+	var out []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) > MaxTagLength || !tagName.MatchString(name) {
+			return nil, apierror.Invalid("invalid tag %q, expected at most %d letters, digits and hyphens", name, MaxTagLength)
+		}
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// RetagRule adds tags to and removes tags from a rule on behalf of its author
+// or a moderator. Tags are made the first time they're used.
+func (r *Resolver) RetagRule(ctx context.Context, id model.GlobalID, add, remove []string) (*model.Rule, error) {
+	userAuth := auth.ForContext(ctx)
+	ruleID, err := id.Of("Rule")
+	if err != nil {
+		return nil, err
+	}
+	if add, err = TagNamesOf(add); err != nil {
+		return nil, err
+	}
+	if remove, err = TagNamesOf(remove); err != nil {
+		return nil, err
+	}
+	cond := database.Rule{ID: ruleID, UserID: userAuth.UserID}
+	// Moderators may tag anyone's rules.
+	if auth.HasRole(userAuth.Role, database.RoleModerator) {
+		cond.UserID = 0
+	}
+	var row database.Rule
+	if err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&cond).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apierror.NotFound("rule %s not found", id)
+			}
+			return fmt.Errorf("database error: %w", err)
+		}
+		if len(add) != 0 {
+			tags := MapOf(add, func(name string) database.Tag { return database.Tag{Name: name} })
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+			var tagIDs []int
+			if err := tx.Model(&database.Tag{}).Where("name IN ?", add).Pluck("id", &tagIDs).Error; err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+			ruleTags := MapOf(tagIDs, func(tagID int) database.RuleTag { return database.RuleTag{RuleID: ruleID, TagID: tagID} })
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&ruleTags).Error; err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+			var count int64
+			if err := tx.Model(&database.RuleTag{}).Where(&database.RuleTag{RuleID: ruleID}).Count(&count).Error; err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+			if count > MaxRuleTags {
+				return apierror.Invalid("rule %s can't have more than %d tags", id, MaxRuleTags)
+			}
+		}
+		if len(remove) != 0 {
+			err := tx.
+				Where("rule_id = ? AND tag_id IN (?)", ruleID, tx.Model(&database.Tag{}).Select("id").Where("name IN ?", remove)).
+				Delete(&database.RuleTag{}).Error
+			if err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	rule := RuleOf(row)
+	return &rule, nil
+}
@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/model"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/webhook"
+	"net/url"
+	"strings"
+)
+
+const MinWebhookSecretLength = 16
+
+// CheckWebhook validates the settings of a webhook.
+func (r *Resolver) CheckWebhook(row database.Webhook) error {
+	u, err := url.Parse(row.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return apierror.Invalid("invalid webhook url %q, expected an http or https URL", row.URL)
+	}
+	if !r.AllowPrivateWebhooks && webhook.PrivateHost(u.Hostname()) {
+		return apierror.Invalid("invalid webhook url %q, expected a public address", row.URL)
+	}
+	if len(row.Secret) < MinWebhookSecretLength {
+		return apierror.Invalid("webhook secret must be at least %d characters", MinWebhookSecretLength)
+	}
+	if row.Events == "" {
+		return apierror.Invalid("webhook must subscribe to at least one event")
+	}
+	return nil
+}
+
+// WebhookEventsOf returns the events column of a webhook subscribed to events.
+func WebhookEventsOf(events []model.WebhookEvent) string {
+	seen := make(map[model.WebhookEvent]struct{}, len(events))
+	var out []string
+	for _, e := range events {
+		if _, ok := seen[e]; !ok {
+			seen[e] = struct{}{}
+			out = append(out, string(e))
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+// WebhookRuleOf and WebhookLikeOf return the data of events, typed for
+// webhook.Enqueue.
+func WebhookRuleOf(row database.Rule) interface{} {
+	return webhook.Rule{
+		ID:      model.GlobalID{Type: "Rule", ID: row.ID}.String(),
+		UserID:  model.GlobalID{Type: "User", ID: row.UserID}.String(),
+		Created: row.Created,
+		Summary: row.Summary,
+		Detail:  row.Detail,
+		Status:  row.Status,
+	}
+}
+
+func WebhookLikeOf(row database.Like) interface{} {
+	return webhook.Like{
+		RuleID: model.GlobalID{Type: "Rule", ID: row.RuleID}.String(),
+		UserID: model.GlobalID{Type: "User", ID: row.UserID}.String(),
+	}
+}
@@ -0,0 +1,197 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/99designs/gqlgen/client"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "0123456789abcdef"
+
+// webhookReceiver records the deliveries posted to it, failing them while
+// fail is set.
+type webhookReceiver struct {
+	mu     sync.Mutex
+	fail   bool
+	bodies []webhook.Body
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	if r.Header.Get(webhook.SignatureHeader) != webhook.Sign(testWebhookSecret, b) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if rcv.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var body webhook.Body
+	if err := json.Unmarshal(b, &body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rcv.bodies = append(rcv.bodies, body)
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	s := newTestServer(t)
+	s.Resolver.AllowPrivateWebhooks = true
+	admin := s.userWithRole("admin", database.RoleAdmin)
+	alice := s.user("alice")
+	rcv := &webhookReceiver{fail: true}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	var resp map[string]interface{}
+	s.post(`mutation($url: String!, $secret: String!) { createWebhook(url: $url, secret: $secret, events: [RULE_CREATED]) { id } }`,
+		&resp, admin, client.Var("url", srv.URL), client.Var("secret", testWebhookSecret))
+	id := s.createRule(alice, "rule")
+	// Not subscribed to.
+	s.post(`mutation($ids: [ID!]) { like(add: $ids) { added } }`, &resp, admin, client.Var("ids", []string{id}))
+
+	d := webhook.Dispatcher{DB: s.DB, AllowPrivate: true}
+	if n, err := d.Dispatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected failed delivery, got %d, %v", n, err)
+	}
+	var row database.WebhookDelivery
+	if err := s.DB.First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.Attempts != 1 || row.LastStatus == nil || *row.LastStatus != http.StatusServiceUnavailable || !row.NextAttempt.After(time.Now()) {
+		t.Fatalf("expected a retry to be scheduled, got %+v", row)
+	}
+	// Not due yet.
+	if n, err := d.Dispatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected no delivery, got %d, %v", n, err)
+	}
+
+	rcv.mu.Lock()
+	rcv.fail = false
+	rcv.mu.Unlock()
+	if err := s.DB.Model(&row).Update("next_attempt", time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.Dispatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected 1 delivery, got %d, %v", n, err)
+	}
+	if len(rcv.bodies) != 1 || rcv.bodies[0].Event != webhook.RuleCreated {
+		t.Fatalf("expected a %s delivery, got %+v", webhook.RuleCreated, rcv.bodies)
+	}
+	var rule webhook.Rule
+	if err := json.Unmarshal(rcv.bodies[0].Data, &rule); err != nil || rule.ID != id {
+		t.Errorf("expected rule %s, got %s", id, rcv.bodies[0].Data)
+	}
+}
+
+func TestWebhookDispatchDoesNotBlock(t *testing.T) {
+	s := newTestServer(t)
+	s.Resolver.AllowPrivateWebhooks = true
+	admin := s.userWithRole("admin", database.RoleAdmin)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var resp map[string]interface{}
+	s.post(`mutation($url: String!, $secret: String!) { createWebhook(url: $url, secret: $secret, events: [RULE_CREATED]) { id } }`,
+		&resp, admin, client.Var("url", srv.URL), client.Var("secret", testWebhookSecret))
+	id := s.createRule(admin, "rule")
+
+	d := webhook.Dispatcher{DB: s.DB, AllowPrivate: true}
+	go d.Dispatch(context.Background())
+	// Wait for the delivery to be claimed.
+	for start := time.Now(); ; time.Sleep(time.Millisecond * 10) {
+		var row database.WebhookDelivery
+		if err := s.DB.First(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+		if row.NextAttempt.After(time.Now()) {
+			break
+		}
+		if time.Since(start) > time.Second*5 {
+			t.Fatal("delivery wasn't claimed")
+		}
+	}
+	// SQLite has one connection, which the dispatcher mustn't hold while it
+	// waits on the receiver.
+	done := make(chan error)
+	go func() {
+		done <- s.Post(`mutation($ids: [ID!]) { like(add: $ids) { added } }`, &resp, admin, client.Var("ids", []string{id}))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("request blocked by webhook delivery")
+	}
+	// Claimed deliveries aren't delivered again.
+	other := webhook.Dispatcher{DB: s.DB, AllowPrivate: true}
+	if n, err := other.Dispatch(context.Background()); err != nil || n != 0 {
+		t.Errorf("expected claimed delivery to be skipped, got %d, %v", n, err)
+	}
+}
+
+func TestWebhookPrivateAddress(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.userWithRole("admin", database.RoleAdmin)
+	for _, u := range []string{
+		"http://localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]:8080/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+	} {
+		var resp map[string]interface{}
+		err := s.Post(`mutation($url: String!, $secret: String!) { createWebhook(url: $url, secret: $secret, events: [RULE_CREATED]) { id } }`,
+			&resp, admin, client.Var("url", u), client.Var("secret", testWebhookSecret))
+		expectCode(t, err, "VALIDATION")
+	}
+
+	// Names that resolve to private addresses are refused when delivering.
+	rcv := &webhookReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+	hook := database.Webhook{
+		URL:     srv.URL,
+		Secret:  testWebhookSecret,
+		Events:  webhook.RuleCreated,
+		Active:  true,
+		Created: time.Now(),
+	}
+	if err := s.DB.Create(&hook).Error; err != nil {
+		t.Fatal(err)
+	}
+	s.createRule(admin, "rule")
+	d := webhook.Dispatcher{DB: s.DB}
+	if n, err := d.Dispatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected failed delivery, got %d, %v", n, err)
+	}
+	var row database.WebhookDelivery
+	if err := s.DB.First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.LastError == nil || !strings.Contains(*row.LastError, webhook.ErrPrivateAddress.Error()) {
+		t.Errorf("expected private address error, got %v", row.LastError)
+	}
+	if len(rcv.bodies) != 0 {
+		t.Errorf("expected no delivery, got %+v", rcv.bodies)
+	}
+}
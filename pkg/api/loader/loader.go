@@ -0,0 +1,119 @@
+package loader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultWait     = time.Millisecond * 2
+	DefaultMaxBatch = 100
+)
+
+// Loader collects the keys loaded by concurrently executing resolvers and
+// fetches them together. Results are cached for the lifetime of the loader,
+// which is a single request.
+type Loader[K comparable, V any] struct {
+	// Fetch returns the values for a batch of keys. Keys missing from the
+	// result load as the zero value.
+	Fetch func(ctx context.Context, keys []K) (map[K]V, error)
+	// Wait is how long to collect keys before fetching a batch.
+	Wait time.Duration
+	// MaxBatch fetches a batch early once it has this many keys.
+	MaxBatch int
+
+	mu    sync.Mutex
+	batch *batch[K, V]
+	cache map[K]*batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	full    chan struct{}
+	done    chan struct{}
+	results map[K]V
+	err     error
+}
+
+func New[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{
+		Fetch:    fetch,
+		Wait:     DefaultWait,
+		MaxBatch: DefaultMaxBatch,
+	}
+}
+
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	return l.LoadThunk(ctx, key)()
+}
+
+// LoadThunk queues a key without waiting for it, so that one resolver can
+// load several keys in the same batch. The returned function waits for the
+// value.
+func (l *Loader[K, V]) LoadThunk(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	b, ok := l.cache[key]
+	if !ok {
+		if l.cache == nil {
+			l.cache = make(map[K]*batch[K, V])
+		}
+		if l.batch == nil {
+			l.batch = &batch[K, V]{
+				full: make(chan struct{}),
+				done: make(chan struct{}),
+			}
+			go l.run(ctx, l.batch)
+		}
+		b = l.batch
+		b.keys = append(b.keys, key)
+		l.cache[key] = b
+		if l.MaxBatch > 0 && len(b.keys) >= l.MaxBatch {
+			l.batch = nil
+			close(b.full)
+		}
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+		if b.err != nil {
+			var zero V
+			return zero, b.err
+		}
+		return b.results[key], nil
+	}
+}
+
+func (l *Loader[K, V]) run(ctx context.Context, b *batch[K, V]) {
+	timer := time.NewTimer(l.Wait)
+	select {
+	case <-timer.C:
+	case <-b.full:
+		timer.Stop()
+	}
+	l.mu.Lock()
+	if l.batch == b {
+		l.batch = nil
+	}
+	keys := b.keys
+	l.mu.Unlock()
+
+	b.results, b.err = l.Fetch(ctx, keys)
+	if b.err != nil {
+		// Don't cache failures, a later load may succeed.
+		l.mu.Lock()
+		for _, key := range keys {
+			if l.cache[key] == b {
+				delete(l.cache, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+	close(b.done)
+}
@@ -0,0 +1,91 @@
+package loader
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLoader loads the doubles of keys, recording each batch fetched.
+func testLoader() (*Loader[int, int], func() [][]int) {
+	var mu sync.Mutex
+	var batches [][]int
+	l := New(func(ctx context.Context, keys []int) (map[int]int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		batch := append([]int(nil), keys...)
+		sort.Ints(batch)
+		batches = append(batches, batch)
+		out := make(map[int]int, len(keys))
+		for _, key := range keys {
+			out[key] = key * 2
+		}
+		return out, nil
+	})
+	return l, func() [][]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return batches
+	}
+}
+
+func loadAll(t *testing.T, l *Loader[int, int], keys ...int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, len(keys))
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			v, err := l.Load(context.Background(), key)
+			if err == nil && v != key*2 {
+				t.Errorf("expected %d for %d, got %d", key*2, key, v)
+			}
+			errs <- err
+		}(key)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoaderBatches(t *testing.T) {
+	l, batches := testLoader()
+	// Fetch once all the keys are in rather than after a time, so the test
+	// doesn't depend on how quickly the loads are scheduled.
+	l.Wait = time.Minute
+	l.MaxBatch = 3
+	loadAll(t, l, 1, 2, 3, 2)
+	if want := [][]int{{1, 2, 3}}; !reflect.DeepEqual(batches(), want) {
+		t.Fatalf("expected batches %v, got %v", want, batches())
+	}
+	// Loaded keys are cached, so only new ones are fetched.
+	l.MaxBatch = 1
+	loadAll(t, l, 1, 4)
+	if want := [][]int{{1, 2, 3}, {4}}; !reflect.DeepEqual(batches(), want) {
+		t.Errorf("expected batches %v, got %v", want, batches())
+	}
+}
+
+func TestLoaderMaxBatch(t *testing.T) {
+	l, batches := testLoader()
+	l.MaxBatch = 2
+	loadAll(t, l, 1, 2, 3)
+	var n int
+	for _, batch := range batches() {
+		if len(batch) > 2 {
+			t.Errorf("expected batches of at most 2 keys, got %v", batch)
+		}
+		n += len(batch)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 keys fetched, got %v", batches())
+	}
+}
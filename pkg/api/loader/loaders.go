@@ -0,0 +1,264 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+type contextKey struct {
+	name string
+}
+
+var loadersCtxKey = &contextKey{
+	name: "loaders",
+}
+
+// PageKey identifies a page of likes belonging to one rule or user.
+type PageKey struct {
+	ID    int
+	After int
+	Limit int
+}
+
+// LikeKey identifies one user's like of one rule.
+type LikeKey struct {
+	UserID int
+	RuleID int
+}
+
+type Page[T any] struct {
+	Rows        []T
+	HasPrevious bool
+	HasNext     bool
+}
+
+type Loaders struct {
+	UserByID    *Loader[int, *database.User]
+	RuleByID    *Loader[int, *database.Rule]
+	CommentByID *Loader[int, *database.Comment]
+	RuleLikes   *Loader[PageKey, *Page[database.User]]
+	UserLikes   *Loader[PageKey, *Page[database.Rule]]
+	Liked       *Loader[LikeKey, bool]
+	RuleTags    *Loader[int, []database.Tag]
+	TagRules    *Loader[int, int]
+}
+
+func NewLoaders(db *database.DB) *Loaders {
+	return &Loaders{
+		UserByID: New(func(ctx context.Context, ids []int) (map[int]*database.User, error) {
+			return byID[database.User](db.WithContext(ctx), ids, func(u database.User) int { return u.ID })
+		}),
+		RuleByID: New(func(ctx context.Context, ids []int) (map[int]*database.Rule, error) {
+			return byID[database.Rule](db.WithContext(ctx), ids, func(r database.Rule) int { return r.ID })
+		}),
+		CommentByID: New(func(ctx context.Context, ids []int) (map[int]*database.Comment, error) {
+			return byID[database.Comment](db.WithContext(ctx), ids, func(c database.Comment) int { return c.ID })
+		}),
+		RuleLikes: New(func(ctx context.Context, keys []PageKey) (map[PageKey]*Page[database.User], error) {
+			return likePages(db.WithContext(ctx), keys, "rule_id", "user_id",
+				func(l database.Like) (int, int) { return l.RuleID, l.UserID },
+				func(u database.User) int { return u.ID })
+		}),
+		UserLikes: New(func(ctx context.Context, keys []PageKey) (map[PageKey]*Page[database.Rule], error) {
+			return likePages(db.WithContext(ctx), keys, "user_id", "rule_id",
+				func(l database.Like) (int, int) { return l.UserID, l.RuleID },
+				func(r database.Rule) int { return r.ID })
+		}),
+		Liked: New(func(ctx context.Context, keys []LikeKey) (map[LikeKey]bool, error) {
+			return liked(db.WithContext(ctx), keys)
+		}),
+		RuleTags: New(func(ctx context.Context, ids []int) (map[int][]database.Tag, error) {
+			return ruleTags(db.WithContext(ctx), ids)
+		}),
+		TagRules: New(func(ctx context.Context, ids []int) (map[int]int, error) {
+			return tagRules(db.WithContext(ctx), ids)
+		}),
+	}
+}
+
+// Operations attaches a fresh set of loaders to every operation so that
+// batches and caches never outlive it, even when one websocket connection
+// carries many.
+func Operations(db *database.DB) graphql.OperationMiddleware {
+	return func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		return next(context.WithValue(ctx, loadersCtxKey, NewLoaders(db)))
+	}
+}
+
+// Responses gives every event of a subscription a fresh set of loaders, as a
+// subscription's operation lasts as long as it's subscribed to.
+func Responses(db *database.DB) graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		if op := graphql.GetOperationContext(ctx); op.Operation != nil && op.Operation.Operation == ast.Subscription {
+			ctx = context.WithValue(ctx, loadersCtxKey, NewLoaders(db))
+		}
+		return next(ctx)
+	}
+}
+
+func For(ctx context.Context) *Loaders {
+	return ctx.Value(loadersCtxKey).(*Loaders)
+}
+
+func byID[T any](db *database.DB, ids []int, id func(T) int) (map[int]*T, error) {
+	var rows []T
+	if err := db.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	out := make(map[int]*T, len(rows))
+	for i := range rows {
+		out[id(rows[i])] = &rows[i]
+	}
+	return out, nil
+}
+
+// liked reads which keys are likes with a query for each user, of whom there
+// is usually only the viewer.
+func liked(db *database.DB, keys []LikeKey) (map[LikeKey]bool, error) {
+	byUser := make(map[int][]int)
+	for _, key := range keys {
+		byUser[key.UserID] = append(byUser[key.UserID], key.RuleID)
+	}
+	out := make(map[LikeKey]bool, len(keys))
+	for userID, ruleIDs := range byUser {
+		var rows []database.Like
+		if err := db.Where("user_id = ? AND rule_id IN ?", userID, ruleIDs).Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		for _, row := range rows {
+			out[LikeKey{UserID: row.UserID, RuleID: row.RuleID}] = true
+		}
+	}
+	return out, nil
+}
+
+// ruleTags reads the tags of rules in name order.
+func ruleTags(db *database.DB, ids []int) (map[int][]database.Tag, error) {
+	var rows []struct {
+		RuleID int
+		ID     int
+		Name   string
+	}
+	err := db.Table("rule_tags").
+		Select("rule_tags.rule_id, tags.id, tags.name").
+		Joins("JOIN tags ON tags.id = rule_tags.tag_id").
+		Where("rule_tags.rule_id IN ?", ids).
+		Order("tags.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	out := make(map[int][]database.Tag, len(ids))
+	for _, row := range rows {
+		out[row.RuleID] = append(out[row.RuleID], database.Tag{ID: row.ID, Name: row.Name})
+	}
+	return out, nil
+}
+
+// tagRules counts the rules tagged with each tag.
+func tagRules(db *database.DB, ids []int) (map[int]int, error) {
+	var rows []struct {
+		TagID int
+		Count int
+	}
+	err := db.Model(&database.RuleTag{}).
+		Select("tag_id, COUNT(*) AS count").
+		Where("tag_id IN ?", ids).
+		Group("tag_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	out := make(map[int]int, len(rows))
+	for _, row := range rows {
+		out[row.TagID] = row.Count
+	}
+	return out, nil
+}
+
+// likePages reads pages of likes for many owners (rules or users) at once.
+// Keys that share after and limit arguments, which is the common case of a
+// list field requested on every item of a page, are read with one windowed
+// query for the likes and one query for the liked items.
+func likePages[T any](
+	db *database.DB,
+	keys []PageKey,
+	ownerCol, itemCol string,
+	split func(database.Like) (owner, item int),
+	id func(T) int,
+) (map[PageKey]*Page[T], error) {
+	type args struct {
+		After int
+		Limit int
+	}
+	groups := make(map[args][]int)
+	for _, key := range keys {
+		a := args{After: key.After, Limit: key.Limit}
+		groups[a] = append(groups[a], key.ID)
+	}
+	out := make(map[PageKey]*Page[T], len(keys))
+	for a, owners := range groups {
+		// Read one extra row per owner to find out whether there is a next page.
+		limit := a.Limit + 1
+		var likes []database.Like
+		err := db.Raw(fmt.Sprintf(`SELECT user_id, rule_id FROM (
+				SELECT user_id, rule_id, ROW_NUMBER() OVER (PARTITION BY %[1]s ORDER BY %[2]s) AS n
+				FROM likes WHERE %[1]s IN ? AND %[2]s > ?
+			) AS page WHERE n <= ? ORDER BY %[1]s, %[2]s`, ownerCol, itemCol),
+			owners, a.After, limit).
+			Scan(&likes).Error
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		prev := make(map[int]bool)
+		if a.After > 0 {
+			var counts []struct {
+				Owner int
+				Count int
+			}
+			err := db.Raw(fmt.Sprintf(`SELECT %[1]s AS owner, COUNT(*) AS count
+				FROM likes WHERE %[1]s IN ? AND %[2]s <= ? GROUP BY %[1]s`, ownerCol, itemCol),
+				owners, a.After).
+				Scan(&counts).Error
+			if err != nil {
+				return nil, fmt.Errorf("database error: %w", err)
+			}
+			for _, c := range counts {
+				prev[c.Owner] = c.Count > 0
+			}
+		}
+		itemIDs := make([]int, 0, len(likes))
+		seen := make(map[int]struct{}, len(likes))
+		for _, like := range likes {
+			_, item := split(like)
+			if _, ok := seen[item]; !ok {
+				seen[item] = struct{}{}
+				itemIDs = append(itemIDs, item)
+			}
+		}
+		items, err := byID(db, itemIDs, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, owner := range owners {
+			out[PageKey{ID: owner, After: a.After, Limit: a.Limit}] = &Page[T]{
+				HasPrevious: prev[owner],
+			}
+		}
+		for _, like := range likes {
+			owner, item := split(like)
+			page := out[PageKey{ID: owner, After: a.After, Limit: a.Limit}]
+			if len(page.Rows) == a.Limit {
+				page.HasNext = true
+				continue
+			}
+			if row, ok := items[item]; ok {
+				page.Rows = append(page.Rows, *row)
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/outbox"
+	"time"
+)
+
+const (
+	DefaultPollInterval = time.Second * 5
+	DefaultBatchSize    = 20
+	MaxAttempts         = 8
+	RetryBackoff        = time.Second * 30
+	// DefaultLease must be longer than it takes to send a batch, or another
+	// dispatcher may claim the same messages.
+	DefaultLease = time.Minute * 15
+)
+
+// Enqueue adds a message to the outbox. Pass the transaction that makes the
+// change the message is about, so the message is sent if and only if the
+// change commits.
+func Enqueue(db *database.DB, msg Message) error {
+	now := time.Now()
+	row := database.OutboxMail{
+		Recipient:   msg.To,
+		Subject:     msg.Subject,
+		Body:        msg.Body,
+		Created:     now,
+		NextAttempt: now,
+	}
+	if err := db.Create(&row).Error; err != nil {
+		return fmt.Errorf("outbox enqueue error: %w", err)
+	}
+	return nil
+}
+
+// Dispatcher sends messages from the outbox, retrying failures with
+// exponential backoff.
+type Dispatcher struct {
+	DB           *database.DB
+	Sender       Sender
+	PollInterval time.Duration
+	BatchSize    int
+	Lease        time.Duration
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	interval := d.PollInterval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+	outbox.Run(ctx, "mail", interval, d.Dispatch)
+}
+
+// Dispatch sends one batch of due messages and returns how many were sent.
+// Messages are claimed by pushing their next attempt back by Lease, so that
+// replicas don't send the same message, and are then sent outside of any
+// transaction.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	size := d.BatchSize
+	if size == 0 {
+		size = DefaultBatchSize
+	}
+	lease := d.Lease
+	if lease == 0 {
+		lease = DefaultLease
+	}
+	o := outbox.Outbox[database.OutboxMail]{
+		DB:           d.DB,
+		Name:         "mail",
+		Done:         "sent",
+		BatchSize:    size,
+		Lease:        lease,
+		MaxAttempts:  MaxAttempts,
+		RetryBackoff: RetryBackoff,
+		ID:           func(row database.OutboxMail) int { return row.ID },
+		Attempts:     func(row database.OutboxMail) int { return row.Attempts },
+		Process: func(ctx context.Context, row database.OutboxMail) (map[string]interface{}, error) {
+			return nil, d.Sender.Send(ctx, Message{To: row.Recipient, Subject: row.Subject, Body: row.Body})
+		},
+	}
+	return o.Dispatch(ctx)
+}
@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"fmt"
+)
+
+func PasswordReset(to, name, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password for your account. If it was you, follow
+the link below within the hour to choose a new password:
+
+%s
+
+If it wasn't you, you can ignore this message.
+`, name, link),
+	}
+}
+
+func EmailVerification(to, name, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(`Hi %s,
+
+Please confirm that this is your email address by following the link below:
+
+%s
+
+If you didn't ask to use this address, you can ignore this message.
+`, name, link),
+	}
+}
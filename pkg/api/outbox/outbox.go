@@ -0,0 +1,128 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// Outbox processes the rows of a table of queued work, retrying failures with
+// exponential backoff. The table has id, attempts, next_attempt and last_error
+// columns, and a column, Done, that records when a row was processed.
+type Outbox[T any] struct {
+	DB   *database.DB
+	Name string
+	// Scope selects the rows that may be processed, besides those that are
+	// done, out of attempts or not yet due.
+	Scope        func(*gorm.DB) *gorm.DB
+	Done         string
+	BatchSize    int
+	Lease        time.Duration
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	ID           func(T) int
+	Attempts     func(T) int
+	// Process processes a row, returning any further columns to update
+	// whether or not it succeeds.
+	Process func(context.Context, T) (map[string]interface{}, error)
+}
+
+// Run dispatches every interval until ctx is done.
+func Run(ctx context.Context, name string, interval time.Duration, dispatch func(context.Context) (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := dispatch(ctx); err != nil {
+			log.Printf("%s dispatch error: %v", name, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Dispatch processes one batch of due rows and returns how many succeeded.
+// Rows are claimed by pushing their next attempt back by Lease, so that
+// replicas don't process the same row, and are then processed outside of any
+// transaction.
+func (o *Outbox[T]) Dispatch(ctx context.Context) (int, error) {
+	rows, err := o.claim(ctx)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	type result struct {
+		id      int
+		updates map[string]interface{}
+	}
+	results := make([]result, 0, len(rows))
+	done := 0
+	for _, row := range rows {
+		updates, err := o.Process(ctx, row)
+		if updates == nil {
+			updates = map[string]interface{}{}
+		}
+		now := time.Now()
+		attempts := o.Attempts(row)
+		updates["attempts"] = attempts + 1
+		if err != nil {
+			updates["last_error"] = err.Error()
+			updates["next_attempt"] = now.Add(o.RetryBackoff << attempts)
+			log.Printf("%s %d error (attempt %d): %v", o.Name, o.ID(row), attempts+1, err)
+		} else {
+			updates["last_error"] = nil
+			updates[o.Done] = now
+			done++
+		}
+		results = append(results, result{o.ID(row), updates})
+	}
+	err = o.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, res := range results {
+			if err := tx.Model(new(T)).Where("id = ?", res.id).Updates(res.updates).Error; err != nil {
+				return fmt.Errorf("database error: %w", err)
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// claim returns a batch of due rows, leasing them to this dispatcher.
+func (o *Outbox[T]) claim(ctx context.Context) ([]T, error) {
+	var rows []T
+	err := o.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		qry := tx.Where(o.Done+" IS NULL AND attempts < ? AND next_attempt <= ?", o.MaxAttempts, now).
+			Order("next_attempt").
+			Limit(o.BatchSize)
+		if o.Scope != nil {
+			qry = qry.Scopes(o.Scope)
+		}
+		if tx.Dialector.Name() == "postgres" {
+			qry = qry.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := qry.Find(&rows).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		ids := make([]int, len(rows))
+		for i, row := range rows {
+			ids[i] = o.ID(row)
+		}
+		err := tx.Model(new(T)).
+			Where("id IN ?", ids).
+			Update("next_attempt", now.Add(o.Lease)).Error
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		return nil
+	})
+	return rows, err
+}
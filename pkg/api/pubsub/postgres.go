@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"log"
+	"time"
+)
+
+const (
+	NotifyChannel  = "benedict_events"
+	ReconnectDelay = time.Second * 3
+)
+
+type notification struct {
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// PostgresBroker fans messages out to every replica with LISTEN/NOTIFY.
+// Messages are only delivered locally once they come back from Postgres, so
+// every replica, including the publisher, sees them in the same order.
+type PostgresBroker struct {
+	hub *Hub
+	db  *database.DB
+}
+
+// NewPostgresBroker publishes with db and listens on a dedicated connection
+// opened from dsn until ctx is done.
+func NewPostgresBroker(ctx context.Context, db *database.DB, dsn string) *PostgresBroker {
+	b := PostgresBroker{
+		hub: NewHub(),
+		db:  db,
+	}
+	go b.listen(ctx, dsn)
+	return &b
+}
+
+func (b *PostgresBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	msg, err := json.Marshal(notification{Topic: topic, Payload: payload})
+	if err != nil {
+		return fmt.Errorf("notification encode error: %w", err)
+	}
+	if err := b.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", NotifyChannel, string(msg)).Error; err != nil {
+		return fmt.Errorf("notify error: %w", err)
+	}
+	return nil
+}
+
+func (b *PostgresBroker) Subscribe(ctx context.Context, topic string) <-chan []byte {
+	return b.hub.Subscribe(ctx, topic)
+}
+
+func (b *PostgresBroker) listen(ctx context.Context, dsn string) {
+	for ctx.Err() == nil {
+		if err := b.listenOnce(ctx, dsn); err != nil && ctx.Err() == nil {
+			log.Printf("pubsub listen error: %v", err)
+			select {
+			case <-time.After(ReconnectDelay):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+func (b *PostgresBroker) listenOnce(ctx context.Context, dsn string) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("connect error: %w", err)
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+NotifyChannel); err != nil {
+		return fmt.Errorf("listen error: %w", err)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("wait error: %w", err)
+		}
+		var msg notification
+		if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+			log.Printf("pubsub notification decode error: %v", err)
+			continue
+		}
+		_ = b.hub.Publish(ctx, msg.Topic, msg.Payload)
+	}
+}
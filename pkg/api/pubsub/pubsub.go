@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+const (
+	RuleCreated = "rule.created"
+	RuleDeleted = "rule.deleted"
+)
+
+// RuleLikes is the topic of like changes to one rule.
+func RuleLikes(ruleID int) string {
+	return fmt.Sprintf("rule.%d.likes", ruleID)
+}
+
+type RuleEvent struct {
+	RuleID int `json:"ruleId"`
+}
+
+type LikeEvent struct {
+	RuleID int  `json:"ruleId"`
+	UserID int  `json:"userId"`
+	Liked  bool `json:"liked"`
+}
+
+// SubscriberBuffer is how many undelivered messages a subscriber may fall
+// behind by before further messages to it are dropped.
+const SubscriberBuffer = 16
+
+// Broker delivers published messages to every current subscriber of a topic.
+// Payloads are JSON so that they can be relayed between replicas.
+type Broker interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe returns a channel of payloads published to topic, which is
+	// closed once ctx is done.
+	Subscribe(ctx context.Context, topic string) <-chan []byte
+}
+
+// Hub is an in-process Broker.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan []byte]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[string]map[chan []byte]struct{}),
+	}
+}
+
+func (h *Hub) Publish(ctx context.Context, topic string, payload []byte) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subs[topic] {
+		// Never let a slow subscriber hold up the publisher.
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+	return nil
+}
+
+func (h *Hub) Subscribe(ctx context.Context, topic string) <-chan []byte {
+	ch := make(chan []byte, SubscriberBuffer)
+	h.mu.Lock()
+	if h.subs[topic] == nil {
+		h.subs[topic] = make(map[chan []byte]struct{})
+	}
+	h.subs[topic][ch] = struct{}{}
+	h.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subs[topic], ch)
+		if len(h.subs[topic]) == 0 {
+			delete(h.subs, topic)
+		}
+		h.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
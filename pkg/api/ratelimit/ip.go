@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct {
+	name string
+}
+
+var ipCtxKey = &contextKey{
+	name: "ip",
+}
+
+// Handle stores the IP address of clients in the context of their requests.
+// Behind a proxy, trustProxy takes the address from the last hop of the
+// X-Forwarded-For header, which is otherwise ignored as clients can set it
+// to anything.
+func Handle(trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+			if trustProxy {
+				if hops := strings.Split(r.Header.Get("X-Forwarded-For"), ","); hops[len(hops)-1] != "" {
+					ip = strings.TrimSpace(hops[len(hops)-1])
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(WithIP(r.Context(), ip)))
+		})
+	}
+}
+
+func WithIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ipCtxKey, ip)
+}
+
+// IPForContext returns the client IP address, or "" if it's unknown.
+func IPForContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ipCtxKey).(string)
+	return ip
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limits table so that limits apply
+// across replicas.
+type PostgresStore struct {
+	DB *database.DB
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	var wait time.Duration
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().Round(0) // Drop monotonic clock reading.
+		row := database.RateLimit{Key: key, Tokens: float64(limit.Burst), Updated: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		qry := tx.Where(&database.RateLimit{Key: key})
+		if tx.Dialector.Name() == "postgres" {
+			qry = qry.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		if err := qry.First(&row).Error; err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		var tokens float64
+		if tokens, wait = take(limit, row.Tokens, row.Updated, now); wait > 0 {
+			return nil
+		}
+		err := tx.Model(&row).Updates(map[string]interface{}{"tokens": tokens, "updated": now}).Error
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		return nil
+	})
+	return wait, err
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) error {
+	if err := s.DB.WithContext(ctx).Where("updated < ?", before).Delete(&database.RateLimit{}).Error; err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+)
+
+// DefaultPruneInterval is longer than any limit of ours takes to refill.
+const DefaultPruneInterval = time.Hour * 24
+
+// Limit allows bursts of up to Burst events and then one every Every.
+type Limit struct {
+	Burst int
+	Every time.Duration
+}
+
+// Store holds the token buckets of rate limits by key.
+type Store interface {
+	// Take takes a token from the bucket of key, returning 0 if it could or
+	// otherwise how long until it can.
+	Take(ctx context.Context, key string, limit Limit) (time.Duration, error)
+	// Prune forgets buckets that haven't been used since before.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// take refills a bucket with tokens at updated and takes a token from it at
+// now. It returns the tokens left, or how long until one can be taken.
+func take(limit Limit, tokens float64, updated, now time.Time) (float64, time.Duration) {
+	if elapsed := now.Sub(updated); elapsed > 0 {
+		tokens += float64(elapsed) / float64(limit.Every)
+	}
+	tokens = math.Min(tokens, float64(limit.Burst))
+	if tokens < 1 {
+		return tokens, time.Duration(math.Ceil((1 - tokens) * float64(limit.Every)))
+	}
+	return tokens - 1, 0
+}
+
+// PruneEvery prunes buckets that have been idle for an interval, which must be
+// longer than any limit takes to refill, until ctx is done.
+func PruneEvery(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := store.Prune(ctx, now.Add(-interval)); err != nil {
+				log.Printf("rate limit prune error: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
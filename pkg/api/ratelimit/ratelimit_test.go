@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	limit := Limit{Burst: 2, Every: time.Second}
+	start := time.Now()
+	for _, test := range []struct {
+		tokens float64
+		at     time.Duration
+		left   float64
+		wait   time.Duration
+	}{
+		{2, 0, 1, 0},
+		{0.5, 0, 0.5, time.Second / 2},
+		{0.5, time.Second, 0.5, 0},
+		// Buckets don't refill past their burst.
+		{1, time.Hour, 1, 0},
+	} {
+		left, wait := take(limit, test.tokens, start, start.Add(test.at))
+		if left != test.left || wait != test.wait {
+			t.Errorf("%v tokens after %v: expected %v left and wait %v, got %v and %v",
+				test.tokens, test.at, test.left, test.wait, left, wait)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	limit := Limit{Burst: 2, Every: time.Hour}
+	for i := 0; i < limit.Burst; i++ {
+		if wait, err := s.Take(ctx, "a", limit); err != nil || wait != 0 {
+			t.Fatalf("take %d: expected no wait, got %v, %v", i, wait, err)
+		}
+	}
+	if wait, _ := s.Take(ctx, "a", limit); wait <= 0 {
+		t.Error("expected to wait once the burst is spent")
+	}
+	if wait, _ := s.Take(ctx, "b", limit); wait != 0 {
+		t.Error("expected keys to have their own buckets")
+	}
+	if err := s.Prune(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := s.Take(ctx, "a", limit); wait != 0 {
+		t.Error("expected a pruned bucket to start full")
+	}
+}
+
+func TestHandle(t *testing.T) {
+	for _, test := range []struct {
+		trustProxy bool
+		forwarded  string
+		want       string
+	}{
+		{false, "", "192.0.2.1"},
+		{false, "198.51.100.1", "192.0.2.1"},
+		{true, "", "192.0.2.1"},
+		{true, "203.0.113.1, 198.51.100.1", "198.51.100.1"},
+	} {
+		var got string
+		h := Handle(test.trustProxy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = IPForContext(r.Context())
+		}))
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if got != test.want {
+			t.Errorf("trustProxy %v, X-Forwarded-For %q: expected %s, got %s", test.trustProxy, test.forwarded, test.want, got)
+		}
+	}
+}
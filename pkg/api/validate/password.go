@@ -0,0 +1,71 @@
+package validate
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// breached is the default list of breached passwords.
+//
+//go:embed breached.txt
+var breached string
+
+// PasswordPolicy is what's required of new passwords.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength bounds the work of hashing a password, unless it's 0.
+	MaxLength int
+	// Breached holds lowercased passwords known from breaches, which are
+	// easily guessed whatever their length.
+	Breached map[string]struct{}
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	list, err := BreachedPasswordsOf(strings.NewReader(breached))
+	if err != nil {
+		panic(err)
+	}
+	return PasswordPolicy{
+		MinLength: 8,
+		MaxLength: 128,
+		Breached:  list,
+	}
+}
+
+// Check checks that password, the value of field, meets the policy.
+func (p PasswordPolicy) Check(field, password string) error {
+	n := utf8.RuneCountInString(password)
+	if n < p.MinLength {
+		return apierror.InvalidField(field, "%s must be at least %d characters", field, p.MinLength)
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		return apierror.InvalidField(field, "%s must be at most %d characters", field, p.MaxLength)
+	}
+	if _, ok := p.Breached[strings.ToLower(password)]; ok {
+		return apierror.InvalidField(field, "%s is too common, it's known from data breaches", field)
+	}
+	return nil
+}
+
+// BreachedPasswordsOf reads a list of breached passwords, one per line.
+// Blank lines and lines starting with # are ignored.
+func BreachedPasswordsOf(r io.Reader) (map[string]struct{}, error) {
+	list := map[string]struct{}{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breached passwords error: %w", err)
+	}
+	return list, nil
+}
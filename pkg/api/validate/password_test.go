@@ -0,0 +1,31 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	list, err := BreachedPasswordsOf(strings.NewReader("# comment\n\nHunter22\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 16, Breached: list}
+	for _, test := range []struct {
+		password string
+		ok       bool
+	}{
+		{"correct horse", true},
+		{"short", false},
+		{"ünïcödé", false},
+		{"ünïcödé!", true},
+		{strings.Repeat("x", 17), false},
+		{"hunter22", false},
+		{"HUNTER22", false},
+		{"# comment", true},
+	} {
+		if err := policy.Check("password", test.password); (err == nil) != test.ok {
+			t.Errorf("%q: expected ok %v, got %v", test.password, test.ok, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package validate
+
+import (
+	"github.com/phyrwork/benevolent-dictator/pkg/api/apierror"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// Length checks that value has at least min and, unless max is 0, at most max
+// characters.
+func Length(field, value string, min, max int) error {
+	n := utf8.RuneCountInString(value)
+	if n < min {
+		if min == 1 {
+			return apierror.InvalidField(field, "%s must not be empty", field)
+		}
+		return apierror.InvalidField(field, "%s must be at least %d characters", field, min)
+	}
+	if max > 0 && n > max {
+		return apierror.InvalidField(field, "%s must be at most %d characters", field, max)
+	}
+	return nil
+}
+
+// NotBlank checks that value has more than whitespace.
+func NotBlank(field, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return apierror.InvalidField(field, "%s must not be blank", field)
+	}
+	return nil
+}
+
+// Email checks that value is a bare email address at a domain name, e.g.
+// someone@example.com.
+func Email(field, value string) error {
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		return apierror.InvalidField(field, "%s must be an email address", field)
+	}
+	domain := value[strings.LastIndex(value, "@")+1:]
+	if !strings.Contains(strings.Trim(domain, "."), ".") {
+		return apierror.InvalidField(field, "%s must be an email address", field)
+	}
+	return nil
+}
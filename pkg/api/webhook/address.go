@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrPrivateAddress = errors.New("private address")
+
+// Private reports whether ip is a loopback, private, link-local, multicast or
+// unspecified address, which webhooks may not be delivered to so that they
+// can't reach services inside our network.
+func Private(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// PrivateHost reports whether host is a private address or a name for the
+// local host. Other names may still resolve to private addresses, which
+// PublicTransport refuses to connect to.
+func PrivateHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && Private(ip)
+}
+
+// PublicTransport returns a transport that refuses to connect to private
+// addresses, after names are resolved so that DNS can't be used to get around
+// it. It doesn't use a proxy, whose address would be checked instead.
+func PublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   DefaultTimeout,
+		KeepAlive: time.Second * 30,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || Private(ip) {
+				return fmt.Errorf("%w %s", ErrPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/outbox"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultPollInterval = time.Second * 5
+	DefaultBatchSize    = 20
+	DefaultTimeout      = time.Second * 10
+	MaxAttempts         = 8
+	RetryBackoff        = time.Second * 30
+	// DefaultLease must be longer than it takes to deliver a batch, or
+	// another dispatcher may claim the same deliveries.
+	DefaultLease = time.Minute * 5
+)
+
+var (
+	publicClient  = &http.Client{Timeout: DefaultTimeout, Transport: PublicTransport()}
+	privateClient = &http.Client{Timeout: DefaultTimeout}
+)
+
+// Dispatcher delivers queued events to webhooks, retrying failures with
+// exponential backoff. A delivery fails unless the webhook responds with a 2xx
+// status. Unless AllowPrivate is set, the default client refuses to connect to
+// private addresses.
+type Dispatcher struct {
+	DB           *database.DB
+	Client       *http.Client
+	AllowPrivate bool
+	PollInterval time.Duration
+	BatchSize    int
+	Lease        time.Duration
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	interval := d.PollInterval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+	outbox.Run(ctx, "webhook", interval, d.Dispatch)
+}
+
+// Dispatch makes one batch of due deliveries and returns how many succeeded.
+// Deliveries are claimed by pushing their next attempt back by Lease, so that
+// replicas don't deliver the same event, and are then made outside of any
+// transaction. Deliveries to inactive webhooks wait until they're reactivated.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	size := d.BatchSize
+	if size == 0 {
+		size = DefaultBatchSize
+	}
+	lease := d.Lease
+	if lease == 0 {
+		lease = DefaultLease
+	}
+	client := d.Client
+	if client == nil {
+		client = publicClient
+		if d.AllowPrivate {
+			client = privateClient
+		}
+	}
+	o := outbox.Outbox[database.WebhookDelivery]{
+		DB:   d.DB,
+		Name: "webhook delivery",
+		Scope: func(db *gorm.DB) *gorm.DB {
+			return db.Preload("Webhook").Where("webhook_id IN (SELECT id FROM webhooks WHERE active)")
+		},
+		Done:         "delivered",
+		BatchSize:    size,
+		Lease:        lease,
+		MaxAttempts:  MaxAttempts,
+		RetryBackoff: RetryBackoff,
+		ID:           func(row database.WebhookDelivery) int { return row.ID },
+		Attempts:     func(row database.WebhookDelivery) int { return row.Attempts },
+		Process: func(ctx context.Context, row database.WebhookDelivery) (map[string]interface{}, error) {
+			status, err := deliver(ctx, client, row)
+			if status == 0 {
+				return nil, err
+			}
+			return map[string]interface{}{"last_status": status}, err
+		},
+	}
+	return o.Dispatch(ctx)
+}
+
+// deliver posts a delivery to its webhook and returns the response status, if
+// there was a response.
+func deliver(ctx context.Context, client *http.Client, row database.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(Body{
+		ID:      row.ID,
+		Event:   row.Event,
+		Created: row.Created,
+		Data:    json.RawMessage(row.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, row.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", row.Event)
+	req.Header.Set(SignatureHeader, Sign(row.Webhook.Secret, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain some of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
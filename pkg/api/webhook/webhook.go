@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"strings"
+	"time"
+)
+
+const (
+	RuleCreated = "RULE_CREATED"
+	RuleDeleted = "RULE_DELETED"
+	RuleLiked   = "RULE_LIKED"
+	RuleUnliked = "RULE_UNLIKED"
+)
+
+var Events = []string{RuleCreated, RuleDeleted, RuleLiked, RuleUnliked}
+
+// SignatureHeader carries the hex HMAC-SHA256 of a delivery's body keyed with
+// its webhook's secret, prefixed with sha256= so that receivers can verify it
+// came from us.
+const SignatureHeader = "X-Webhook-Signature-256"
+
+// Rule is the data of rule events. IDs are GraphQL IDs.
+type Rule struct {
+	ID      string    `json:"id"`
+	UserID  string    `json:"userId"`
+	Created time.Time `json:"created"`
+	Summary string    `json:"summary"`
+	Detail  *string   `json:"detail"`
+	Status  string    `json:"status"`
+}
+
+// Like is the data of like events. IDs are GraphQL IDs.
+type Like struct {
+	RuleID string `json:"ruleId"`
+	UserID string `json:"userId"`
+}
+
+// Body is what's posted to a webhook. ID is the same for every attempt to
+// deliver an event so that receivers can ignore repeats.
+type Body struct {
+	ID      int             `json:"id"`
+	Event   string          `json:"event"`
+	Created time.Time       `json:"created"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func EventsOf(hook database.Webhook) []string {
+	if hook.Events == "" {
+		return nil
+	}
+	return strings.Split(hook.Events, ",")
+}
+
+func Subscribes(hook database.Webhook, event string) bool {
+	for _, e := range EventsOf(hook) {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue queues deliveries of events with data to every active webhook
+// subscribed to them. Pass the transaction that makes the change the events
+// are about, so the events are delivered if and only if the change commits.
+func Enqueue(db *database.DB, event string, data ...interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	payloads := make([]string, len(data))
+	for i, d := range data {
+		b, err := json.Marshal(d)
+		if err != nil {
+			return fmt.Errorf("webhook payload error: %w", err)
+		}
+		payloads[i] = string(b)
+	}
+	var hooks []database.Webhook
+	if err := db.Where(&database.Webhook{Active: true}).Find(&hooks).Error; err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	now := time.Now()
+	var rows []database.WebhookDelivery
+	for _, hook := range hooks {
+		if !Subscribes(hook, event) {
+			continue
+		}
+		for _, payload := range payloads {
+			rows = append(rows, database.WebhookDelivery{
+				WebhookID:   hook.ID,
+				Event:       event,
+				Payload:     payload,
+				Created:     now,
+				NextAttempt: now,
+			})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		return fmt.Errorf("webhook enqueue error: %w", err)
+	}
+	return nil
+}
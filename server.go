@@ -1,16 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/phyrwork/benevolent-dictator/pkg/api/auth"
 	"github.com/phyrwork/benevolent-dictator/pkg/api/database"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/loader"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/mail"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/pubsub"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/ratelimit"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/validate"
+	"github.com/phyrwork/benevolent-dictator/pkg/api/webhook"
 	"gorm.io/gorm"
 	"log"
+	"net"
 	"net/http"
+	"net/smtp"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/phyrwork/benevolent-dictator/pkg/api/graph"
 	"github.com/phyrwork/benevolent-dictator/pkg/api/graph/generated"
@@ -18,18 +31,18 @@ import (
 
 const defaultPort = "8080"
 
-func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+func openDB() (*gorm.DB, string) {
+	// DATABASE_URL selects the driver by scheme, e.g. sqlite://dev.db for
+	// local development without Postgres.
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = fmt.Sprintf(
+			"host=%s user=dictator password=%s dbname=dictator sslmode=disable",
+			os.Getenv("DB_SERVICE_HOST"),
+			os.Getenv("DB_SERVICE_PASSWORD"))
+		log.Printf("database dsn=%s", dsn)
 	}
 
-	dsn := fmt.Sprintf(
-		"host=%s user=dictator password=%s dbname=dictator sslmode=disable",
-		os.Getenv("DB_SERVICE_HOST"),
-		os.Getenv("DB_SERVICE_PASSWORD"))
-	log.Printf("database dsn=%s", dsn)
-
 	var err error
 	var db *gorm.DB
 	for db == nil {
@@ -41,18 +54,314 @@ func main() {
 		time.Sleep(time.Second * 3)
 	}
 	log.Printf("database open ok")
-	if err = database.Migrate(db); err != nil {
+	return db, dsn
+}
+
+// newBroker keeps events in-process, which is enough for a single replica.
+// PUBSUB=postgres relays them between replicas through Postgres instead.
+func newBroker(ctx context.Context, db *gorm.DB, dsn string) pubsub.Broker {
+	switch os.Getenv("PUBSUB") {
+	case "", "memory":
+		return pubsub.NewHub()
+	case "postgres":
+		if db.Dialector.Name() != "postgres" {
+			log.Fatalf("PUBSUB postgres needs a postgres DATABASE_URL")
+		}
+		return pubsub.NewPostgresBroker(ctx, db, dsn)
+	default:
+		log.Fatalf("unknown PUBSUB %s", os.Getenv("PUBSUB"))
+		return nil
+	}
+}
+
+// newRateLimits keeps rate limits in Postgres when possible, so they apply
+// across replicas. RATE_LIMITS=memory keeps them in each replica instead.
+func newRateLimits(db *gorm.DB) ratelimit.Store {
+	switch os.Getenv("RATE_LIMITS") {
+	case "memory":
+		return ratelimit.NewMemoryStore()
+	case "postgres":
+		return &ratelimit.PostgresStore{DB: db}
+	case "":
+		if db.Dialector.Name() == "postgres" {
+			return &ratelimit.PostgresStore{DB: db}
+		}
+		return ratelimit.NewMemoryStore()
+	default:
+		log.Fatalf("unknown RATE_LIMITS %s", os.Getenv("RATE_LIMITS"))
+		return nil
+	}
+}
+
+// newMailSender picks where outbox mail goes with MAIL_SENDER: smtp, file
+// (into MAIL_DIR) or log, the default.
+func newMailSender() mail.Sender {
+	switch os.Getenv("MAIL_SENDER") {
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			log.Fatalf("invalid SMTP_ADDR %s: %v", addr, err)
+		}
+		sender := mail.SMTPSender{
+			Addr: addr,
+			From: os.Getenv("MAIL_FROM"),
+		}
+		if user := os.Getenv("SMTP_USER"); user != "" {
+			sender.Auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		return sender
+	case "file":
+		return mail.FileSender{Dir: os.Getenv("MAIL_DIR")}
+	case "log", "":
+		return mail.LogSender{}
+	default:
+		log.Fatalf("unknown MAIL_SENDER %s", os.Getenv("MAIL_SENDER"))
+		return nil
+	}
+}
+
+// grant sets a user's role, e.g. to designate the dictator.
+func grant(db *gorm.DB, args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: server grant email role")
+	}
+	if !auth.ValidRole(args[1]) {
+		log.Fatalf("invalid role %s, expected one of %v", args[1], database.Roles)
+	}
+	res := db.Model(&database.User{}).Where(&database.User{Email: args[0]}).Update("role", args[1])
+	if res.Error != nil {
+		log.Fatalf("database error: %v", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		log.Fatalf("user %s not found", args[0])
+	}
+	log.Printf("granted %s role %s", args[0], args[1])
+}
+
+// reconcile repairs rule like counts that have drifted from the likes.
+func reconcile(db *gorm.DB, args []string) {
+	if len(args) != 0 {
+		log.Fatal("usage: server reconcile")
+	}
+	repaired, err := database.ReconcileLikeCounts(db)
+	if err != nil {
+		log.Fatalf("like count reconcile error: %v", err)
+	}
+	log.Printf("repaired %d like counts", repaired)
+}
+
+// newPasswordPolicy adjusts the default password policy with
+// PASSWORD_MIN_LENGTH and adds the passwords listed one per line in the file
+// BREACHED_PASSWORDS to those that are refused.
+func newPasswordPolicy() validate.PasswordPolicy {
+	policy := validate.DefaultPasswordPolicy()
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("invalid PASSWORD_MIN_LENGTH %s", v)
+		}
+		policy.MinLength = n
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("open BREACHED_PASSWORDS error: %v", err)
+		}
+		defer f.Close()
+		list, err := validate.BreachedPasswordsOf(f)
+		if err != nil {
+			log.Fatalf("read BREACHED_PASSWORDS error: %v", err)
+		}
+		for password := range list {
+			policy.Breached[password] = struct{}{}
+		}
+		log.Printf("loaded %d breached passwords from %s", len(list), path)
+	}
+	return policy
+}
+
+// envInt reads a non-negative integer from an environment variable, where 0
+// turns off whatever it limits.
+func envInt(name string, fallback int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Fatalf("invalid %s %s", name, v)
+	}
+	return n
+}
+
+func newServer(db *gorm.DB, broker pubsub.Broker, rateLimits ratelimit.Store, baseURL string) *handler.Server {
+	proposalPeriod := time.Hour * 24 * 7
+	if v := os.Getenv("PROPOSAL_PERIOD"); v != "" {
+		var err error
+		if proposalPeriod, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("invalid PROPOSAL_PERIOD %s: %v", v, err)
+		}
+	}
+	requestTimeout := graph.DefaultRequestTimeout
+	if v := os.Getenv("REQUEST_TIMEOUT"); v != "" {
+		var err error
+		if requestTimeout, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("invalid REQUEST_TIMEOUT %s: %v", v, err)
+		}
+	}
+	resolver := &graph.Resolver{
+		DB:                    db,
+		PubSub:                broker,
+		BaseURL:               baseURL,
+		VerifiedEmailRequired: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		ProposalPeriod:        proposalPeriod,
+		PasswordPolicy:        newPasswordPolicy(),
+		RateLimits:            rateLimits,
+	}
+	srv := handler.New(generated.NewExecutableSchema(graph.NewConfig(resolver)))
+
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		// Browsers can't set headers on websockets, so the access token is
+		// sent in the connection init payload instead. The connection is
+		// closed once its session ends.
+		InitFunc: func(ctx context.Context, initPayload transport.InitPayload) (context.Context, error) {
+			if userAuth := auth.Authenticate(ctx, db, initPayload.Authorization()); userAuth != nil {
+				ctx = auth.WithUserAuth(ctx, userAuth)
+				ctx = auth.WatchSession(ctx, db, userAuth, auth.SessionCheckInterval)
+			}
+			return ctx, nil
+		},
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+
+	srv.SetQueryCache(lru.New(1000))
+
+	srv.Use(extension.Introspection{})
+	srv.Use(&graph.QueryLimit{
+		MaxDepth:      envInt("MAX_QUERY_DEPTH", graph.DefaultMaxDepth),
+		MaxComplexity: envInt("MAX_QUERY_COMPLEXITY", graph.DefaultMaxComplexity),
+	})
+	srv.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New(100),
+	})
+	srv.AroundOperations(loader.Operations(db))
+	srv.AroundResponses(loader.Responses(db))
+	srv.AroundResponses(graph.Timeout(requestTimeout))
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+
+	return srv
+}
+
+func migrate(db *gorm.DB, args []string) {
+	usage := "usage: server migrate up|down [steps]|status"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	switch args[0] {
+	case "up":
+		done, err := database.MigrateUp(db)
+		for _, m := range done {
+			log.Printf("applied %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("database migrate error: %v", err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal(usage)
+			}
+		}
+		done, err := database.MigrateDown(db, steps)
+		for _, m := range done {
+			log.Printf("reverted %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("database migrate error: %v", err)
+		}
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			log.Fatalf("database migrate error: %v", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied != nil {
+				applied = status.Applied.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+	default:
+		log.Fatal(usage)
+	}
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = defaultPort
+	}
+
+	db, dsn := openDB()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			migrate(db, os.Args[2:])
+			return
+		case "grant":
+			grant(db, os.Args[2:])
+			return
+		case "reconcile":
+			reconcile(db, os.Args[2:])
+			return
+		default:
+			log.Fatalf("unknown command %s", os.Args[1])
+		}
+	}
+	if err := database.Migrate(db); err != nil {
 		log.Fatalf("database migrate error: %v", err)
 	}
 	log.Printf("database migration ok")
 
-	resolver := &graph.Resolver{
-		DB: db,
+	baseURL := os.Getenv("APP_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+	rateLimits := newRateLimits(db)
+	go ratelimit.PruneEvery(context.Background(), rateLimits, ratelimit.DefaultPruneInterval)
+	go auth.PruneLoginFailuresEvery(context.Background(), db, auth.LockoutMax)
+	srv := newServer(db, newBroker(context.Background(), db, dsn), rateLimits, baseURL)
+
+	dispatcher := mail.Dispatcher{
+		DB:     db,
+		Sender: newMailSender(),
+	}
+	go dispatcher.Run(context.Background())
+
+	hooks := webhook.Dispatcher{DB: db}
+	go hooks.Run(context.Background())
+
+	reconcileInterval := database.DefaultReconcileInterval
+	if v := os.Getenv("RECONCILE_INTERVAL"); v != "" {
+		var err error
+		if reconcileInterval, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("invalid RECONCILE_INTERVAL %s: %v", v, err)
+		}
 	}
-	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	go database.ReconcileLikeCountsEvery(context.Background(), db, reconcileInterval)
 
 	mux := http.NewServeMux()
-	mux.Handle("/query", auth.Handle(srv))
+	// Behind a proxy, TRUST_PROXY=true takes client addresses from
+	// X-Forwarded-For for rate limits.
+	clientIP := ratelimit.Handle(os.Getenv("TRUST_PROXY") == "true")
+	mux.Handle("/query", clientIP(auth.Handle(db)(srv)))
 	mux.Handle("/playground", playground.Handler("GraphQL playground", "/query"))
 	mux.Handle("/", http.FileServer(http.Dir("dist"))) // TODO: What to do about development environment?
 
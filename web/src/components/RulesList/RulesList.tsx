@@ -18,14 +18,14 @@ const RulesList: React.FC<Props> = ({ data }) => (
                 <td>Created by</td>
                 <td># Likes</td>
             </tr>
-                {!!data.rules && data.rules.rules.map(
-                    (rule, i) =>
+                {!!data.rules && data.rules.edges.map(
+                    ({ node: rule }, i) =>
                         !!rule && (
-                            <tr key={i}>
-                                <td>{rule.id}</td>
+                            <tr key={rule.id}>
+                                <td>{i + 1}</td>
                                 <td>{rule.summary}</td>
                                 <td>{rule.user.name}</td>
-                                <td>{rule.likes.users.length}</td>
+                                <td>{rule.likeCount}</td>
                             </tr>
                         ),
                 )}
@@ -3,7 +3,7 @@ import { useRulesListQuery } from '../../generated/graphql';
 import RulesList from './RulesList';
 
 const RulesListContainer = () => {
-    const { data, error, loading } = useRulesListQuery({ variables: { limit: 20 } });
+    const { data, error, loading } = useRulesListQuery({ variables: { first: 20 } });
 
     if (loading) {
         return <div>Loading...</div>;
@@ -1,18 +1,16 @@
 import { gql } from '@apollo/client';
 
 export const QUERY_RULES_LIST = gql`
-    query RulesList($limit: Int!) {
-        rules(limit: $limit) {
-            rules {
-                id
-                user {
-                    name
-                }
-                summary
-                likes(limit: 20) {
-                    users {
-                        id
+    query RulesList($first: Int!) {
+        rules(first: $first) {
+            edges {
+                node {
+                    id
+                    user {
+                        name
                     }
+                    summary
+                    likeCount
                 }
             }
         }
@@ -15,10 +15,29 @@ export type Scalars = {
   Float: number;
 };
 
+export type DiffChunk = {
+  __typename?: 'DiffChunk';
+  op: DiffOp;
+  text: Scalars['String'];
+};
+
+export enum DiffOp {
+  Delete = 'DELETE',
+  Equal = 'EQUAL',
+  Insert = 'INSERT'
+}
+
+export type LikesChange = {
+  __typename?: 'LikesChange';
+  liked: Scalars['Boolean'];
+  ruleId: Scalars['ID'];
+  user: User;
+};
+
 export type LikesUpdate = {
   __typename?: 'LikesUpdate';
-  added: Array<Scalars['Int']>;
-  removed: Array<Scalars['Int']>;
+  added: Array<Scalars['ID']>;
+  removed: Array<Scalars['ID']>;
 };
 
 export type Mutation = {
@@ -28,7 +47,18 @@ export type Mutation = {
   deleteRule?: Maybe<Scalars['ID']>;
   like?: Maybe<LikesUpdate>;
   login: UserToken;
+  logout: Scalars['Boolean'];
+  logoutAllSessions: Scalars['Int'];
+  ratifyRule: Rule;
+  refresh: UserToken;
+  repealRule: Rule;
+  requestPasswordReset: Scalars['Boolean'];
+  resetPassword: Scalars['Boolean'];
+  setUserRole: User;
+  updateRule: Rule;
   updateUser: User;
+  verifyEmail: Scalars['Boolean'];
+  vetoRule: Rule;
 };
 
 
@@ -62,113 +92,296 @@ export type MutationLoginArgs = {
 };
 
 
+export type MutationRatifyRuleArgs = {
+  id: Scalars['ID'];
+};
+
+
+export type MutationRefreshArgs = {
+  refreshToken: Scalars['String'];
+};
+
+
+export type MutationRepealRuleArgs = {
+  id: Scalars['ID'];
+};
+
+
+export type MutationRequestPasswordResetArgs = {
+  email: Scalars['String'];
+};
+
+
+export type MutationResetPasswordArgs = {
+  newPassword: Scalars['String'];
+  token: Scalars['String'];
+};
+
+
+export type MutationSetUserRoleArgs = {
+  role: Role;
+  userId: Scalars['ID'];
+};
+
+
+export type MutationUpdateRuleArgs = {
+  detail?: InputMaybe<Scalars['String']>;
+  id: Scalars['ID'];
+  summary: Scalars['String'];
+};
+
+
 export type MutationUpdateUserArgs = {
+  email?: InputMaybe<Scalars['String']>;
   name?: InputMaybe<Scalars['String']>;
 };
 
+
+export type MutationVerifyEmailArgs = {
+  token: Scalars['String'];
+};
+
+
+export type MutationVetoRuleArgs = {
+  id: Scalars['ID'];
+};
+
+export type Node = {
+  id: Scalars['ID'];
+};
+
 export type PageInfo = {
   __typename?: 'PageInfo';
-  endCursor?: Maybe<Scalars['ID']>;
+  endCursor?: Maybe<Scalars['String']>;
   hasNextPage: Scalars['Boolean'];
   hasPreviousPage: Scalars['Boolean'];
-  startCursor?: Maybe<Scalars['ID']>;
+  startCursor?: Maybe<Scalars['String']>;
 };
 
 export type Query = {
   __typename?: 'Query';
-  rules: RulePage;
-  users: UserPage;
+  node?: Maybe<Node>;
+  nodes: Array<Maybe<Node>>;
+  rules: RuleConnection;
+  searchRules: RuleSearchConnection;
+  users: UserConnection;
+};
+
+
+export type QueryNodeArgs = {
+  id: Scalars['ID'];
+};
+
+
+export type QueryNodesArgs = {
+  ids: Array<Scalars['ID']>;
 };
 
 
 export type QueryRulesArgs = {
-  after?: Scalars['Int'];
-  limit?: Scalars['Int'];
-  userId?: InputMaybe<Scalars['ID']>;
+  after?: InputMaybe<Scalars['String']>;
+  before?: InputMaybe<Scalars['String']>;
+  filter?: InputMaybe<RuleFilter>;
+  first?: InputMaybe<Scalars['Int']>;
+  last?: InputMaybe<Scalars['Int']>;
+  orderBy?: InputMaybe<RuleOrder>;
+};
+
+
+export type QuerySearchRulesArgs = {
+  after?: InputMaybe<Scalars['String']>;
+  first?: InputMaybe<Scalars['Int']>;
+  query: Scalars['String'];
 };
 
 
 export type QueryUsersArgs = {
-  after?: Scalars['Int'];
-  limit?: Scalars['Int'];
+  after?: InputMaybe<Scalars['String']>;
+  before?: InputMaybe<Scalars['String']>;
+  first?: InputMaybe<Scalars['Int']>;
+  last?: InputMaybe<Scalars['Int']>;
   name?: InputMaybe<Scalars['String']>;
 };
 
-export type Rule = {
+export type RevisionDiff = {
+  __typename?: 'RevisionDiff';
+  detail: Array<DiffChunk>;
+  from: Scalars['Int'];
+  summary: Array<DiffChunk>;
+  to: Scalars['Int'];
+};
+
+export enum Role {
+  Admin = 'ADMIN',
+  Dictator = 'DICTATOR',
+  Member = 'MEMBER',
+  Moderator = 'MODERATOR'
+}
+
+export type Rule = Node & {
   __typename?: 'Rule';
   created: Scalars['String'];
+  decided?: Maybe<Scalars['String']>;
+  decider?: Maybe<User>;
   detail?: Maybe<Scalars['String']>;
+  diff: RevisionDiff;
   id: Scalars['ID'];
-  likes: UserPage;
+  likeCount: Scalars['Int'];
+  likes: UserConnection;
+  revisions: Array<RuleRevision>;
+  status: RuleStatus;
   summary: Scalars['String'];
+  updated?: Maybe<Scalars['String']>;
   user: User;
+  viewerHasLiked: Scalars['Boolean'];
+  votingEnds?: Maybe<Scalars['String']>;
+};
+
+
+export type RuleDiffArgs = {
+  from: Scalars['Int'];
+  to: Scalars['Int'];
 };
 
 
 export type RuleLikesArgs = {
-  after?: Scalars['Int'];
-  limit?: Scalars['Int'];
+  after?: InputMaybe<Scalars['String']>;
+  first?: InputMaybe<Scalars['Int']>;
+};
+
+export type RuleConnection = {
+  __typename?: 'RuleConnection';
+  edges: Array<RuleEdge>;
+  pageInfo: PageInfo;
+};
+
+export type RuleEdge = {
+  __typename?: 'RuleEdge';
+  cursor: Scalars['String'];
+  node: Rule;
+};
+
+export type RuleFilter = {
+  createdAfter?: InputMaybe<Scalars['String']>;
+  createdBefore?: InputMaybe<Scalars['String']>;
+  likedBy?: InputMaybe<Scalars['ID']>;
+  status?: InputMaybe<Array<RuleStatus>>;
+  text?: InputMaybe<Scalars['String']>;
+  userId?: InputMaybe<Scalars['ID']>;
+};
+
+export enum RuleOrder {
+  MostLiked = 'MOST_LIKED',
+  Newest = 'NEWEST',
+  Oldest = 'OLDEST',
+  Trending = 'TRENDING'
+}
+
+export type RuleRevision = {
+  __typename?: 'RuleRevision';
+  created: Scalars['String'];
+  detail?: Maybe<Scalars['String']>;
+  editor: User;
+  number: Scalars['Int'];
+  summary: Scalars['String'];
 };
 
-export type RulePage = {
-  __typename?: 'RulePage';
+export type RuleSearchConnection = {
+  __typename?: 'RuleSearchConnection';
+  edges: Array<RuleSearchEdge>;
   pageInfo: PageInfo;
-  rules: Array<Rule>;
 };
 
-export type User = {
+export type RuleSearchEdge = {
+  __typename?: 'RuleSearchEdge';
+  cursor: Scalars['String'];
+  detail?: Maybe<Scalars['String']>;
+  node: Rule;
+  rank: Scalars['Float'];
+  summary: Scalars['String'];
+};
+
+export enum RuleStatus {
+  Proposed = 'PROPOSED',
+  Ratified = 'RATIFIED',
+  Repealed = 'REPEALED',
+  Vetoed = 'VETOED'
+}
+
+export type Subscription = {
+  __typename?: 'Subscription';
+  likesChanged: LikesChange;
+  ruleCreated: Rule;
+  ruleDeleted: Scalars['ID'];
+};
+
+
+export type SubscriptionLikesChangedArgs = {
+  ruleId: Scalars['ID'];
+};
+
+export type User = Node & {
   __typename?: 'User';
   id: Scalars['ID'];
-  likes: RulePage;
+  likes: RuleConnection;
   name: Scalars['String'];
-  rules: RulePage;
+  role: Role;
+  rules: RuleConnection;
 };
 
 
 export type UserLikesArgs = {
-  after?: Scalars['Int'];
-  limit?: Scalars['Int'];
+  after?: InputMaybe<Scalars['String']>;
+  first?: InputMaybe<Scalars['Int']>;
 };
 
 
 export type UserRulesArgs = {
-  after?: Scalars['Int'];
-  limit?: Scalars['Int'];
+  after?: InputMaybe<Scalars['String']>;
+  before?: InputMaybe<Scalars['String']>;
+  first?: InputMaybe<Scalars['Int']>;
+  last?: InputMaybe<Scalars['Int']>;
 };
 
-export type UserPage = {
-  __typename?: 'UserPage';
+export type UserConnection = {
+  __typename?: 'UserConnection';
+  edges: Array<UserEdge>;
   pageInfo: PageInfo;
-  users: Array<User>;
+};
+
+export type UserEdge = {
+  __typename?: 'UserEdge';
+  cursor: Scalars['String'];
+  node: User;
 };
 
 export type UserToken = {
   __typename?: 'UserToken';
   expiresAt: Scalars['Int'];
+  refreshExpiresAt: Scalars['Int'];
+  refreshToken: Scalars['String'];
   token: Scalars['String'];
 };
 
 export type RulesListQueryVariables = Exact<{
-  limit: Scalars['Int'];
+  first: Scalars['Int'];
 }>;
 
 
-export type RulesListQuery = { __typename?: 'Query', rules: { __typename?: 'RulePage', rules: Array<{ __typename?: 'Rule', id: string, summary: string, user: { __typename?: 'User', name: string }, likes: { __typename?: 'UserPage', users: Array<{ __typename?: 'User', id: string }> } }> } };
+export type RulesListQuery = { __typename?: 'Query', rules: { __typename?: 'RuleConnection', edges: Array<{ __typename?: 'RuleEdge', node: { __typename?: 'Rule', id: string, summary: string, likeCount: number, user: { __typename?: 'User', name: string } } }> } };
 
 
 export const RulesListDocument = gql`
-    query RulesList($limit: Int!) {
-  rules(limit: $limit) {
-    rules {
-      id
-      user {
-        name
-      }
-      summary
-      likes(limit: 20) {
-        users {
-          id
+    query RulesList($first: Int!) {
+  rules(first: $first) {
+    edges {
+      node {
+        id
+        user {
+          name
         }
+        summary
+        likeCount
       }
     }
   }
@@ -187,7 +400,7 @@ export const RulesListDocument = gql`
  * @example
  * const { data, loading, error } = useRulesListQuery({
  *   variables: {
- *      limit: // value for 'limit'
+ *      first: // value for 'first'
  *   },
  * });
  */